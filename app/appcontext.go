@@ -4,7 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"reflect"
+	"sync"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -21,15 +22,11 @@ import (
 	"github.com/ca17/go-common/log"
 )
 
-// 获取数据库连接，执行一次
+// 获取数据库连接，执行一次, 根据 config.Type 选择方言
 func GetDatabase(config *conf.DBConfig) *sqlx.DB {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.User,
-		config.Passwd,
-		config.Host,
-		config.Port,
-		config.Name)
-	pool, err := sqlx.Open("mysql", dsn)
+	dialect, err := GetDialect(config.Type)
+	common.Must(err)
+	pool, err := sqlx.Open(dialect.DriverName(), dialect.DSN(config))
 	common.Must(err)
 	pool.SetMaxOpenConns(config.MaxConn)
 	pool.SetMaxIdleConns(config.MaxIdle)
@@ -71,6 +68,10 @@ type ContextManager interface {
 
 type AppContext struct {
 	Context ContextManager
+	// 表策略, 见 RegisterTablePolicy
+	policies sync.Map
+	hooksMu  sync.RWMutex
+	hooks    []QueryHook
 }
 
 func (m *AppContext) Set(key string, val interface{}) {
@@ -105,6 +106,8 @@ type PageResult struct {
 	TotalCount int64       `json:"total_count,omitempty"`
 	Pos        int64       `json:"pos"`
 	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
 }
 
 // 空分页对象
@@ -121,6 +124,8 @@ type CrudGet struct {
 	Culumns   []string
 	Filter    map[string]interface{}
 	ResultRef interface{}
+	// 包含已软删除的记录
+	WithDeleted bool
 }
 
 func NewCrudGet(table string, culumns []string, filter map[string]interface{}, resultRef interface{}) *CrudGet {
@@ -136,8 +141,11 @@ type CrudQuery struct {
 	Table      string
 	Culumns    []string
 	Tags       string
+	TagColumn  string
+	TagMatch   TagMatch
 	LikeNames  []string
 	LikeValue  interface{}
+	LikeMode   LikeMode
 	DateRange  DateRange
 	DateColumn string
 	Joins      []string
@@ -148,11 +156,26 @@ type CrudQuery struct {
 	OrderBy    string
 	Limit      uint64
 	Wheres     []string
+	Filters    []CrudFilter // 声明式过滤条件, 见 FilterSpec
 	Pager      bool
 	PageSize   uint64
 	PagePos    uint64
-	ResultRef  interface{}
-	ResultPage *PageResult
+	// 游标分页, 设置 CursorColumn 后忽略 PagePos 与 OrderBy
+	CursorColumn string
+	// 游标列不唯一时的次序列, 应为唯一列, 如主键
+	CursorTieColumn string
+	CursorDesc      bool
+	Cursor          string
+	// 总数统计方式
+	CountMode     CountMode
+	CountCacheTTL time.Duration
+	// 包含已软删除的记录
+	WithDeleted bool
+	ResultRef   interface{}
+	ResultPage  *PageResult
+	// 当前查询的软删除过滤列
+	softDelete string
+	dialect    Dialect
 }
 
 func (cq *CrudQuery) SetEqValue(key, val string) {
@@ -172,6 +195,15 @@ func NewCrudQuery(table string, culumns []string, resultRef interface{}) *CrudQu
 type CrudAdd struct {
 	Table string
 	Vals  []map[string]interface{}
+	// 每批写入行数, 默认 DefaultBatchSize
+	BatchSize int
+	Mode      InsertMode
+	// 冲突时更新的列, 仅 InsertUpsert 有效
+	UpdateColumns []string
+	// 冲突判断的唯一键列, postgres 与 sqlite 的 InsertUpsert 必须设置
+	ConflictColumns []string
+	// 每批写入的影响行数
+	BatchAffected []int64
 }
 
 func NewCrudAdd(table string, vals []map[string]interface{}) *CrudAdd {
@@ -185,141 +217,234 @@ type CrudUpdate struct {
 	Eq     sq.Eq
 	LtOrEq sq.LtOrEq
 	GtOrEq sq.GtOrEq
+	// 乐观锁版本列, 设置后更新时 version = version + 1 并校验旧版本 Version
+	VersionColumn string
+	Version       interface{}
 }
 
 func NewCrudUpdate(table string, vals map[string]interface{}, filter map[string]interface{}) *CrudUpdate {
 	return &CrudUpdate{Table: table, Vals: vals, Eq: filter}
 }
 
+// sqlExecer 为 *sql.Tx 与 *sqlx.DB 的公共执行接口
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// 优先使用指定事务执行, 其次为 ctx 中的事务, 否则使用连接池
+func (m *AppContext) execer(ctx context.Context, tx *sql.Tx) sqlExecer {
+	if tx != nil {
+		return tx
+	}
+	if ctxTx, ok := TxFromContext(ctx); ok {
+		return ctxTx
+	}
+	return m.Context.DBPool()
+}
+
+// 查询时优先使用 ctx 中的事务, 否则使用只读连接池
+func (m *AppContext) queryer(ctx context.Context) sqlx.QueryerContext {
+	if ctxTx, ok := TxFromContext(ctx); ok {
+		return ctxTx
+	}
+	return m.ReaderPool(ctx)
+}
+
+// 查询单行, 按方言替换占位符
+func (m *AppContext) getContext(ctx context.Context, table string, dest interface{}, query string, args ...interface{}) error {
+	query = m.rebind(query)
+	return m.instrument(ctx, QueryGet, table, query, args, func(ctx context.Context) (int64, error) {
+		if err := sqlx.GetContext(ctx, m.queryer(ctx), dest, query, args...); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
+}
+
+// 查询多行, 按方言替换占位符
+func (m *AppContext) selectContext(ctx context.Context, table string, dest interface{}, query string, args ...interface{}) error {
+	query = m.rebind(query)
+	return m.instrument(ctx, QuerySelect, table, query, args, func(ctx context.Context) (int64, error) {
+		if err := sqlx.SelectContext(ctx, m.queryer(ctx), dest, query, args...); err != nil {
+			return 0, err
+		}
+		return int64(reflect.Indirect(reflect.ValueOf(dest)).Len()), nil
+	})
+}
+
 // CRUD 获取单个对象
 func (m *AppContext) DBGet2(table string, culumns []string, filter map[string]interface{}, resultRef interface{}) error {
 	return m.DBGet(NewCrudGet(table, culumns, filter, resultRef))
 }
 
 func (m *AppContext) DBGet(cg *CrudGet) error {
-	sql, args, _ := sq.
+	return m.DBGetContext(context.Background(), cg)
+}
+
+// CRUD 获取单个对象, 支持 context 取消
+func (m *AppContext) DBGetContext(ctx context.Context, cg *CrudGet) error {
+	b := sq.
 		Select(cg.Culumns...).
 		From(cg.Table).
-		Where(cg.Filter).Limit(1).
-		ToSql()
-
-	err := m.Context.DBPool().Get(cg.ResultRef, sql, args...)
+		Where(cg.Filter).Limit(1)
+	if col := m.softDeleteColumn(ctx, cg.Table, cg.WithDeleted, false); col != "" {
+		b = b.Where(sq.Eq{col: nil})
+	}
+	sql, args, err := b.ToSql()
 	if err != nil {
-		log.Error(err)
+		return err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sql, args)
+	}
+	err = m.getContext(ctx, cg.Table, cg.ResultRef, sql, args...)
+	if err != nil {
+		log.ErrorCtx(ctx, err)
 		return err
 	}
 	return nil
 }
 
-// CRUD 查询列表
-func (m *AppContext) DBQuery(cq *CrudQuery) error {
-	// 查询过滤
-	var filterBuilder = func(b sq.SelectBuilder) sq.SelectBuilder {
+// 查询过滤, 过滤列不合法或过滤条件参数个数不足时返回错误
+func (cq *CrudQuery) filterBuilder(b sq.SelectBuilder) (sq.SelectBuilder, error) {
 
-		if cq.Tags != "" {
-			orwheres := []string{}
-			for _, tag := range strings.Split(cq.Tags, ",") {
-				orwheres = append(orwheres, fmt.Sprintf("FIND_IN_SET(\"%s\", tags)", tag))
-			}
-			b = b.Where(fmt.Sprintf(" ( %s ) ", strings.Join(orwheres, " or ")))
-		}
+	if cq.softDelete != "" {
+		b = b.Where(sq.Eq{cq.softDelete: nil})
+	}
 
-		if cq.DateColumn != "" {
-			if cq.DateRange.End != "" {
-				b = b.Where(sq.LtOrEq{cq.DateColumn: cq.DateRange.End})
-			}
-			if cq.DateRange.Start != "" {
-				b = b.Where(sq.GtOrEq{cq.DateColumn: cq.DateRange.Start})
-			}
+	cond, err := cq.tagFilter()
+	if err != nil {
+		return b, err
+	}
+	if cond != nil {
+		b = b.Where(cond)
+	}
+
+	for _, f := range cq.Filters {
+		fc, err := f.toSqlizer(cq.getDialect())
+		if err != nil {
+			return b, err
 		}
+		b = b.Where(fc)
+	}
 
-		if cq.Joins != nil {
-			for _, join := range cq.Joins {
-				b = b.Join(join)
-			}
+	if cq.DateColumn != "" {
+		if cq.DateRange.End != "" {
+			b = b.Where(sq.LtOrEq{cq.DateColumn: cq.DateRange.End})
+		}
+		if cq.DateRange.Start != "" {
+			b = b.Where(sq.GtOrEq{cq.DateColumn: cq.DateRange.Start})
 		}
+	}
 
-		if cq.LeftJoins != nil {
-			for _, join := range cq.LeftJoins {
-				b = b.LeftJoin(join)
-			}
+	if cq.Joins != nil {
+		for _, join := range cq.Joins {
+			b = b.Join(join)
 		}
+	}
 
-		if cq.Wheres != nil && len(cq.Wheres) > 0 {
-			for _, where := range cq.Wheres {
-				if where != "" {
-					b = b.Where(where)
-				}
-			}
+	if cq.LeftJoins != nil {
+		for _, join := range cq.LeftJoins {
+			b = b.LeftJoin(join)
 		}
+	}
 
-		if cq.LikeValue != "" && cq.LikeNames != nil {
-			like := fmt.Sprintf("%s%%", cq.LikeValue)
-			ormap := sq.Or{}
-			for _, name := range cq.LikeNames {
-				ormap = append(ormap, sq.Like{name: like})
+	if cq.Wheres != nil && len(cq.Wheres) > 0 {
+		for _, where := range cq.Wheres {
+			if where != "" {
+				b = b.Where(where)
 			}
-			b = b.Where(ormap)
 		}
+	}
 
-		if cq.Eq != nil {
-			b = b.Where(cq.Eq)
-		}
+	if cond, err = cq.likeFilter(); err != nil {
+		return b, err
+	}
+	if cond != nil {
+		b = b.Where(cond)
+	}
 
-		if cq.LtOrEq != nil {
-			b = b.Where(cq.LtOrEq)
-		}
+	if cq.Eq != nil {
+		b = b.Where(cq.Eq)
+	}
 
-		if cq.GtOrEq != nil {
-			b = b.Where(cq.GtOrEq)
-		}
+	if cq.LtOrEq != nil {
+		b = b.Where(cq.LtOrEq)
+	}
 
-		if cq.OrderBy != "" {
-			b = b.OrderBy(cq.OrderBy)
-		}
+	if cq.GtOrEq != nil {
+		b = b.Where(cq.GtOrEq)
+	}
+
+	return b, nil
+}
+
+// CRUD 查询列表
+func (m *AppContext) DBQuery(cq *CrudQuery) error {
+	return m.DBQueryContext(context.Background(), cq)
+}
+
+// 设置查询使用的方言与软删除列
+func (m *AppContext) prepareQuery(ctx context.Context, cq *CrudQuery) {
+	cq.softDelete = m.softDeleteColumn(ctx, cq.Table, cq.WithDeleted, len(cq.Joins)+len(cq.LeftJoins) > 0)
+	cq.dialect = m.Dialect()
+}
 
-		return b
+// 生成查询语句, 包含过滤, 排序与 Limit, 不处理分页
+func (cq *CrudQuery) buildSelect() (sq.SelectBuilder, error) {
+	bs, err := cq.filterBuilder(sq.Select(cq.Culumns...).From(cq.Table))
+	if err != nil {
+		return bs, err
+	}
+	if cq.OrderBy != "" {
+		bs = bs.OrderBy(cq.OrderBy)
+	}
+	if cq.Limit > 0 {
+		bs = bs.Limit(cq.Limit)
+	}
+	return bs, nil
+}
+
+// CRUD 查询列表, 支持 context 取消
+func (m *AppContext) DBQueryContext(ctx context.Context, cq *CrudQuery) error {
+	m.prepareQuery(ctx, cq)
+	if cq.Pager && cq.CursorColumn != "" {
+		return m.dbCursorQuery(ctx, cq)
 	}
-	bs := sq.Select(cq.Culumns...).From(cq.Table)
-	bs = filterBuilder(bs)
 
-	// 设置分页查询参数
+	bs, err := cq.buildSelect()
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+
+	// 设置分页查询参数, 覆盖 Limit
 	if cq.Pager {
 		cq.ResultPage = EmptyPageResult
 		bs = bs.Offset(cq.PagePos).Limit(cq.PageSize)
-	} else {
-		if cq.Limit > 0 {
-			bs = bs.Limit(cq.Limit)
-		}
 	}
 
 	// 查询数据
-	sql, args, _ := bs.ToSql()
+	sql, args, err := bs.ToSql()
+	if err != nil {
+		return err
+	}
 	if log.IsDebug() {
-		log.Debug(sql, args)
+		log.DebugCtx(ctx, sql, args)
 	}
-	err := m.Context.DBPool().Select(cq.ResultRef, sql, args...)
+	err = m.selectContext(ctx, cq.Table, cq.ResultRef, sql, args...)
 	if err != nil {
-		log.Error(err)
+		log.ErrorCtx(ctx, err)
 		return err
 	}
 
 	// 封装分页结果
 	if cq.Pager {
-		var total int64 = 0
-		if cq.PagePos == 0 {
-			bc := sq.Select("count(*)").From(cq.Table)
-			bc = filterBuilder(bc)
-			sqlbc, argsbc, _ := bc.ToSql()
-			if log.IsDebug() {
-				log.Debug(sql, args)
-			}
-			err := m.Context.DBPool().Get(&total, sqlbc, argsbc...)
-			if err != nil {
-				log.Error(err)
-				cq.ResultPage = EmptyPageResult
-				return err
-			}
+		total, err := m.pageCount(ctx, cq, cq.PagePos == 0)
+		if err != nil {
+			cq.ResultPage = EmptyPageResult
+			return err
 		}
 		cq.ResultPage = &PageResult{Data: cq.ResultRef, Pos: int64(cq.PagePos), TotalCount: total}
 	}
@@ -331,73 +456,71 @@ func (m *AppContext) DBQuery(cq *CrudQuery) error {
 func (m *AppContext) DBInsert(table string, vals map[string]interface{}) error {
 	return m.DBInsertWithTx(nil, table, vals)
 }
+
 func (m *AppContext) DBInsertWithTx(tx *sql.Tx, table string, vals map[string]interface{}) error {
-	var cols []string
-	var values []interface{}
-	for k, v := range vals {
-		cols = append(cols, k)
-		values = append(values, v)
-	}
-	sql, args, err := sq.Insert(table).Columns(cols...).Values(values...).ToSql()
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-	if log.IsDebug() {
-		log.Debug(sql, args)
-	}
-	if tx != nil {
-		_, err = tx.Exec(sql, args...)
-	} else {
-		_, err = m.Context.DBPool().Exec(sql, args...)
-	}
+	return m.DBInsertWithTxContext(context.Background(), tx, table, vals)
+}
 
-	if err != nil {
-		return err
-	}
-	return nil
+// CRUD 增加数据对象, 支持 context 取消
+func (m *AppContext) DBInsertContext(ctx context.Context, table string, vals map[string]interface{}) error {
+	return m.DBInsertWithTxContext(ctx, nil, table, vals)
+}
+
+func (m *AppContext) DBInsertWithTxContext(ctx context.Context, tx *sql.Tx, table string, vals map[string]interface{}) error {
+	_, err := m.DBInsertWithTxResult(ctx, tx, table, vals)
+	return err
 }
 
 // CRUD 增加数据对象
 func (m *AppContext) DBAdd(ca *CrudAdd) error {
-	tx, err := m.Context.DBPool().Begin()
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	for _, valmap := range ca.Vals {
-		var cols []string
-		var values []interface{}
+	_, err := m.DBAddContext(context.Background(), ca)
+	return err
+}
 
-		for k, v := range valmap {
-			cols = append(cols, k)
-			values = append(values, v)
-		}
-		sql, args, err := sq.Insert(ca.Table).Columns(cols...).Values(values...).ToSql()
-		if err != nil {
-			log.Error(err)
-			return err
+// CRUD 批量增加数据对象, 按 BatchSize 合并为多行 INSERT, 返回写入的总行数.
+// ctx 中已存在事务时加入该事务, 否则开启新事务
+func (m *AppContext) DBAddContext(ctx context.Context, ca *CrudAdd) (int64, error) {
+	rows := ca.Vals
+	if m.GetTablePolicy(ca.Table) != nil {
+		rows = make([]map[string]interface{}, 0, len(ca.Vals))
+		for _, valmap := range ca.Vals {
+			rows = append(rows, m.fillInsertAudit(ctx, ca.Table, valmap))
 		}
+	}
+	batches, err := ca.batchBuilders(m.Dialect(), rows)
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return 0, err
+	}
+	var total int64
+	var affected []int64
+	err = m.WithTx(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+		affected = make([]int64, 0, len(batches))
+		for _, b := range batches {
+			sql, args, err := b.ToSql()
+			if err != nil {
+				log.ErrorCtx(ctx, err)
+				return err
+			}
 
-		if log.IsDebug() {
-			log.Debug(sql, args)
-		}
+			if log.IsDebug() {
+				log.DebugCtx(ctx, sql, args)
+			}
 
-		_, err = tx.Exec(sql, args...)
-		if err != nil {
-			_ = tx.Rollback()
-			log.Error(err)
-			return err
+			r, err := m.execResult(ctx, nil, ca.Table, sql, args...)
+			if err != nil {
+				return err
+			}
+			affected = append(affected, r.RowsAffected)
+			total += r.RowsAffected
 		}
-	}
-
-	err = tx.Commit()
+		return nil
+	})
 	if err != nil {
-		log.Error(err)
-		return err
+		return 0, err
 	}
-	return nil
+	ca.BatchAffected = affected
+	return total, nil
 }
 
 // CRUD 数据更新
@@ -414,32 +537,17 @@ func (m *AppContext) DBUpdate2WithTx(tx *sql.Tx, table string, vals map[string]i
 }
 
 func (m *AppContext) DBUpdate(cu *CrudUpdate) error {
-	b := sq.Update(cu.Table).SetMap(cu.Vals)
-	if cu.Eq != nil {
-		b = b.Where(cu.Eq)
-	}
-	if cu.LtOrEq != nil {
-		b = b.Where(cu.LtOrEq)
-	}
+	_, err := m.DBUpdateContext(context.Background(), cu)
+	return err
+}
 
-	if cu.GtOrEq != nil {
-		b = b.Where(cu.GtOrEq)
-	}
-	sql, args, _ := b.ToSql()
-	if log.IsDebug() {
-		log.Debug(sql, args)
-	}
-	var err error
-	if cu.tx != nil {
-		_, err = cu.tx.Exec(sql, args...)
-	} else {
-		_, err = m.Context.DBPool().Exec(sql, args...)
-	}
+// CRUD 数据更新, 支持 context 取消, 返回影响行数
+func (m *AppContext) DBUpdateContext(ctx context.Context, cu *CrudUpdate) (int64, error) {
+	r, err := m.DBUpdateResult(ctx, cu)
 	if err != nil {
-		log.Error(err)
-		return err
+		return 0, err
 	}
-	return nil
+	return r.RowsAffected, nil
 }
 
 // 根据id删除表数据
@@ -448,20 +556,20 @@ func (m *AppContext) DBDelete(table string, ids []string) error {
 }
 
 func (m *AppContext) DBDeleteWithTx(tx *sql.Tx, table string, ids []string) error {
-	for _, id := range ids {
-		sql, args, _ := sq.Delete(table).Where(sq.Eq{"id": id}).ToSql()
-		var err error
-		if tx != nil {
-			_, err = tx.Exec(sql, args...)
-		} else {
-			_, err = m.Context.DBPool().Exec(sql, args...)
-		}
-		if err != nil {
-			log.Error(err)
-			continue
-		}
+	_, err := m.DBDeleteWithTxContext(context.Background(), tx, table, ids)
+	return err
+}
+
+// 根据id删除表数据, 支持 context 取消, 返回删除行数
+func (m *AppContext) DBDeleteContext(ctx context.Context, table string, ids []string) (int64, error) {
+	return m.DBDeleteWithTxContext(ctx, nil, table, ids)
+}
+
+func (m *AppContext) DBDeleteWithTxContext(ctx context.Context, tx *sql.Tx, table string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
 	}
-	return nil
+	return m.DBDeleteWithFilterTxContext(ctx, tx, table, sq.Eq{"id": ids})
 }
 
 func (m *AppContext) DBDeleteWithFilter(table string, filter map[string]interface{}) error {
@@ -469,21 +577,56 @@ func (m *AppContext) DBDeleteWithFilter(table string, filter map[string]interfac
 }
 
 func (m *AppContext) DBDeleteWithFilterTx(tx *sql.Tx, table string, filter map[string]interface{}) error {
-	sql, args, _ := sq.Delete(table).Where(filter).ToSql()
+	_, err := m.DBDeleteWithFilterTxContext(context.Background(), tx, table, filter)
+	return err
+}
+
+// 根据条件删除表数据, 支持 context 取消, 返回删除行数
+func (m *AppContext) DBDeleteWithFilterContext(ctx context.Context, table string, filter map[string]interface{}) (int64, error) {
+	return m.DBDeleteWithFilterTxContext(ctx, nil, table, filter)
+}
+
+func (m *AppContext) DBDeleteWithFilterTxContext(ctx context.Context, tx *sql.Tx, table string, filter map[string]interface{}) (int64, error) {
+	var sql string
+	var args []interface{}
 	var err error
-	if tx != nil {
-		_, err = tx.Exec(sql, args...)
+	if sb := m.softDeleteBuilder(ctx, table, filter); sb != nil {
+		sql, args, err = sb.ToSql()
 	} else {
-		_, err = m.Context.DBPool().Exec(sql, args...)
+		sql, args, err = sq.Delete(table).Where(filter).ToSql()
 	}
 	if err != nil {
-		log.Error(err)
+		return 0, err
 	}
-	return nil
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sql, args)
+	}
+	return m.execAffected(ctx, tx, table, sql, args...)
 }
 
 // 清空表
 func (m *AppContext) DBTrucate(table string) error {
-	_, err := m.Context.DBPool().Exec("TRUNCATE TABLE " + table)
+	return m.DBTrucateContext(context.Background(), table)
+}
+
+// 清空表, 支持 context 取消
+func (m *AppContext) DBTrucateContext(ctx context.Context, table string) error {
+	query := m.Dialect().TruncateSQL(table)
+	err := m.instrument(ctx, QueryExec, table, query, nil, func(ctx context.Context) (int64, error) {
+		_, err := m.execer(ctx, nil).ExecContext(ctx, query)
+		return 0, err
+	})
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+	}
 	return err
 }
+
+// 执行 SQL 并返回影响行数
+func (m *AppContext) execAffected(ctx context.Context, tx *sql.Tx, table string, query string, args ...interface{}) (int64, error) {
+	r, err := m.execResult(ctx, tx, table, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected, nil
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// 已取消的 context 不再执行查询
+func TestQueryContextCanceled(t *testing.T) {
+	m := newSqliteAppContext(t)
+	if err := insertProduct(context.Background(), m, "apple"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var items []testProduct
+	cq := NewCrudQuery("product", []string{"id", "name"}, &items)
+	if err := m.DBQueryContext(ctx, cq); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from DBQueryContext, got %v", err)
+	}
+	var item testProduct
+	cg := NewCrudGet("product", []string{"id", "name"}, map[string]interface{}{"name": "apple"}, &item)
+	if err := m.DBGetContext(ctx, cg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from DBGetContext, got %v", err)
+	}
+	if err := insertProduct(ctx, m, "banana"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from DBInsertContext, got %v", err)
+	}
+	if names := productNames(t, m); len(names) != 1 {
+		t.Fatalf("canceled insert should not write, got %v", names)
+	}
+}
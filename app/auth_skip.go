@@ -0,0 +1,194 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 跳过规则来源
+const (
+	SkipSourceBuiltin = "builtin"
+	SkipSourceConfig  = "config"
+	SkipSourceHandler = "handler"
+	SkipSourceContext = "context"
+)
+
+// 认证跳过规则, Method 为空或 * 时匹配全部方法.
+// Path 按段匹配请求路径, 不含查询参数: :name 与 * 匹配单段, ** 匹配零或多段,
+// 段内可使用 path.Match 通配符, 如 /static/*.js
+type SkipRule struct {
+	Method string `yaml:"method" json:"method"`
+	Path   string `yaml:"path" json:"path"`
+	Source string `yaml:"-" json:"source"`
+}
+
+// 解析 "GET /login" 或 "/login" 格式的规则
+func ParseSkipRule(s string) (SkipRule, error) {
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		return SkipRule{Path: fields[0]}, checkSkipPath(fields[0])
+	case 2:
+		return SkipRule{Method: strings.ToUpper(fields[0]), Path: fields[1]}, checkSkipPath(fields[1])
+	}
+	return SkipRule{}, fmt.Errorf("invalid auth skip rule %q", s)
+}
+
+func checkSkipPath(p string) error {
+	if !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("auth skip path %q must start with /", p)
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid auth skip path %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (r SkipRule) String() string {
+	method := r.Method
+	if method == "" {
+		method = "*"
+	}
+	return fmt.Sprintf("%-7s %s (%s)", method, r.Path, r.Source)
+}
+
+// 判断请求是否匹配规则
+func (r SkipRule) Match(method string, urlPath string) bool {
+	if r.Method != "" && r.Method != "*" && r.Method != method {
+		return false
+	}
+	return matchSegments(splitPath(r.Path), splitPath(urlPath))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern []string, segs []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			for j := i; j <= len(segs); j++ {
+				if matchSegments(pattern[i+1:], segs[j:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if i >= len(segs) {
+			return false
+		}
+		if strings.HasPrefix(p, ":") {
+			continue
+		}
+		if ok, _ := path.Match(p, segs[i]); !ok {
+			return false
+		}
+	}
+	return len(pattern) == len(segs)
+}
+
+// 认证跳过规则集合
+type SkipRules struct {
+	mu    sync.RWMutex
+	rules []SkipRule
+}
+
+func NewSkipRules() *SkipRules {
+	return &SkipRules{}
+}
+
+// 添加规则, 未设置来源时记为 source
+func (s *SkipRules) Add(source string, rules ...SkipRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range rules {
+		if r.Source == "" {
+			r.Source = source
+		}
+		r.Method = strings.ToUpper(r.Method)
+		s.rules = append(s.rules, r)
+	}
+}
+
+// 解析并添加逗号分隔的规则列表, 如 WebConfig.AuthSkip
+func (s *SkipRules) AddList(source string, list string) error {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		rule, err := ParseSkipRule(item)
+		if err != nil {
+			return err
+		}
+		s.Add(source, rule)
+	}
+	return nil
+}
+
+// 当前生效的规则
+func (s *SkipRules) Rules() []SkipRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SkipRule(nil), s.rules...)
+}
+
+// 判断请求是否跳过认证
+func (s *SkipRules) Match(c echo.Context) bool {
+	req := c.Request()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.rules {
+		if r.Match(req.Method, req.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// 规则列表文本, 用于调试输出
+func (s *SkipRules) Dump() string {
+	var sb strings.Builder
+	for _, r := range s.Rules() {
+		sb.WriteString(r.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// WebHandler 可选实现, 声明无需认证的路由
+type AuthSkipper interface {
+	AuthSkipRules() []SkipRule
+}
+
+// 兼容上下文中的 AuthSkipUri 与 AuthSkipPrefix 配置.
+// 前缀按路径段匹配, /api/pub 不再匹配 /api/public
+func contextSkipRules(appContext *AppContext) []SkipRule {
+	if appContext == nil {
+		return nil
+	}
+	var rules []SkipRule
+	if v, ok := appContext.Get(AuthSkipUri); ok {
+		for _, p := range v.([]string) {
+			rules = append(rules, SkipRule{Path: p})
+		}
+	}
+	if v, ok := appContext.Get(AuthSkipPrefix); ok {
+		for _, p := range v.([]string) {
+			rules = append(rules, SkipRule{Path: strings.TrimSuffix(p, "/") + "/**"})
+		}
+	}
+	if v, ok := appContext.Get(AuthSkipRules); ok {
+		rules = append(rules, v.([]SkipRule)...)
+	}
+	return rules
+}
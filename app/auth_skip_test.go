@@ -0,0 +1,83 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/conf"
+)
+
+func TestSkipRuleMatch(t *testing.T) {
+	cases := []struct {
+		rule   string
+		method string
+		path   string
+		ok     bool
+	}{
+		{"/login", "POST", "/login", true},
+		{"POST /login", "GET", "/login", false},
+		{"/api/pub/**", "GET", "/api/pub", true},
+		{"/api/pub/**", "GET", "/api/pub/a/b", true},
+		{"/api/pub/**", "GET", "/api/public", false},
+		{"/user/:id/avatar", "GET", "/user/12/avatar", true},
+		{"/user/:id/avatar", "GET", "/user/12/profile", false},
+		{"/static/*.js", "GET", "/static/app.js", true},
+		{"/static/*.js", "GET", "/static/app.css", false},
+		{"/**/*.png", "GET", "/a/b/c.png", true},
+	}
+	for _, c := range cases {
+		rule, err := ParseSkipRule(c.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule.Match(c.method, c.path) != c.ok {
+			t.Fatalf("%s %s %s expected %v", c.rule, c.method, c.path, c.ok)
+		}
+	}
+	if _, err := ParseSkipRule("login"); err == nil {
+		t.Fatal("expected invalid rule error")
+	}
+}
+
+type publicHandler struct{}
+
+func (publicHandler) InitRouter(webctx *WebContext, g *echo.Group) {
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	g.POST("/login", ok)
+	g.GET("/share/:code", ok)
+	g.GET("/api/public", ok)
+}
+
+func (publicHandler) AuthSkipRules() []SkipRule {
+	return []SkipRule{{Method: http.MethodGet, Path: "/share/:code"}}
+}
+
+func TestServerAuthSkip(t *testing.T) {
+	m := newSqliteAppContext(t)
+	m.Set(AuthSkipPrefix, []string{"/api/pub"})
+	config := &testAppConfig{web: conf.WebConfig{Secret: "secret", AuthSkip: "POST /login"}, prod: true}
+	s := NewServer(config, m, nil, publicHandler{})
+	cases := []struct {
+		method string
+		uri    string
+		code   int
+	}{
+		{http.MethodPost, "/login?from=home", http.StatusOK},
+		{http.MethodGet, "/share/abc", http.StatusOK},
+		{http.MethodGet, "/api/public", http.StatusBadRequest},
+		{http.MethodGet, ReadyzPath, http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		s.Echo.ServeHTTP(rec, httptest.NewRequest(c.method, c.uri, nil))
+		if rec.Code != c.code {
+			t.Fatalf("%s %s expected %d, got %d", c.method, c.uri, c.code, rec.Code)
+		}
+	}
+	if len(s.AuthSkip.Rules()) != 5 {
+		t.Fatalf("unexpected rules\n%s", s.AuthSkip.Dump())
+	}
+}
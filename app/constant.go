@@ -1,6 +1,19 @@
 package app
 
 const (
-	AuthSkipUri    = "AuthSkipUri"
+	// Deprecated: 使用 AuthSkipRules
+	AuthSkipUri = "AuthSkipUri"
+	// Deprecated: 使用 AuthSkipRules, 前缀按路径段匹配
 	AuthSkipPrefix = "AuthSkipPrefix"
+	// []SkipRule, 无需认证的路由规则
+	AuthSkipRules = "AuthSkipRules"
+	// *auth.Manager, 设置后替代 WebConfig.Secret 校验令牌
+	AuthManager = "AuthManager"
+	// *RBAC, 未设置时使用空角色配置
+	RBACManager = "RBACManager"
+	// RateLimitStore, 设置后替代 WebConfig.RateLimitStore
+	RateLimiterStore = "RateLimitStore"
 )
+
+// echo.Context 中用户偏好的语言, 如 en 或 zh-CN, 优先于 Accept-Language
+const ContextLocale = "locale"
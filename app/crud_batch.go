@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// 批量写入方式
+type InsertMode int
+
+const (
+	// 普通 INSERT
+	InsertNormal InsertMode = iota
+	// 忽略重复键, mysql 为 INSERT IGNORE
+	InsertIgnore
+	// 冲突时更新, mysql 为 INSERT ... ON DUPLICATE KEY UPDATE
+	InsertUpsert
+)
+
+// 默认每批写入行数
+const DefaultBatchSize = 500
+
+// 按列名排序, 保证生成的 SQL 列顺序稳定
+func sortedColumns(vals map[string]interface{}) []string {
+	cols := make([]string, 0, len(vals))
+	for k := range vals {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// 列相同的一组行
+type rowGroup struct {
+	cols []string
+	rows []map[string]interface{}
+}
+
+// 按列分组, merge 为 true 时合并为一组, 缺失列使用默认值,
+// 否则相邻且列相同的行为一组, 保持写入顺序
+func groupRows(rows []map[string]interface{}, merge bool) []rowGroup {
+	if merge {
+		colset := make(map[string]interface{})
+		for _, valmap := range rows {
+			for k := range valmap {
+				colset[k] = nil
+			}
+		}
+		return []rowGroup{{cols: sortedColumns(colset), rows: rows}}
+	}
+	var groups []rowGroup
+	var last string
+	for _, valmap := range rows {
+		cols := sortedColumns(valmap)
+		key := strings.Join(cols, ",")
+		if len(groups) == 0 || key != last {
+			groups = append(groups, rowGroup{cols: cols})
+			last = key
+		}
+		g := &groups[len(groups)-1]
+		g.rows = append(g.rows, valmap)
+	}
+	return groups
+}
+
+// 生成分批的多行 INSERT, 各行缺少的列使用方言的默认值.
+// 冲突更新时按列分组, 只更新本组各行都提供的列, 避免以默认值覆盖已有数据.
+// 每批行数不超过 BatchSize, 且占位符数量不超过方言上限
+func (ca *CrudAdd) batchBuilders(d Dialect, rows []map[string]interface{}) ([]sq.InsertBuilder, error) {
+	if ca.Mode == InsertUpsert {
+		if len(ca.UpdateColumns) == 0 {
+			return nil, fmt.Errorf("upsert into %s requires update columns", ca.Table)
+		}
+		upsertCols := make([]string, 0, len(ca.UpdateColumns)+len(ca.ConflictColumns))
+		upsertCols = append(append(upsertCols, ca.UpdateColumns...), ca.ConflictColumns...)
+		for _, col := range upsertCols {
+			if !IsSafeIdentifier(col) {
+				return nil, fmt.Errorf("invalid upsert column %s", col)
+			}
+		}
+	}
+
+	var batches []sq.InsertBuilder
+	merge := d.MissingValue() != nil && ca.Mode != InsertUpsert
+	for _, g := range groupRows(rows, merge) {
+		if len(g.cols) == 0 {
+			return nil, fmt.Errorf("insert into %s requires columns", ca.Table)
+		}
+		ignore := ca.Mode == InsertIgnore
+		var suffix string
+		if ca.Mode == InsertUpsert {
+			updates := make([]string, 0, len(ca.UpdateColumns))
+			for _, col := range ca.UpdateColumns {
+				if _, ok := g.rows[0][col]; ok {
+					updates = append(updates, col)
+				}
+			}
+			if len(updates) == 0 {
+				// 没有可更新的列, 冲突时保留原有数据
+				ignore = true
+			} else {
+				var err error
+				if suffix, err = d.UpsertSuffix(ca.ConflictColumns, updates); err != nil {
+					return nil, err
+				}
+			}
+		}
+		size := ca.BatchSize
+		if size <= 0 {
+			size = DefaultBatchSize
+		}
+		if max := d.MaxPlaceholders() / len(g.cols); max < size {
+			size = max
+		}
+		if size == 0 {
+			return nil, fmt.Errorf("insert into %s has too many columns", ca.Table)
+		}
+		for start := 0; start < len(g.rows); start += size {
+			end := start + size
+			if end > len(g.rows) {
+				end = len(g.rows)
+			}
+			b := sq.Insert(ca.Table).Columns(g.cols...)
+			if ignore {
+				b = d.InsertIgnore(b)
+			}
+			for _, valmap := range g.rows[start:end] {
+				values := make([]interface{}, 0, len(g.cols))
+				for _, col := range g.cols {
+					if v, ok := valmap[col]; ok {
+						values = append(values, v)
+					} else {
+						values = append(values, d.MissingValue())
+					}
+				}
+				b = b.Values(values...)
+			}
+			if suffix != "" {
+				b = b.Suffix(suffix)
+			}
+			batches = append(batches, b)
+		}
+	}
+	return batches, nil
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBatchBuilders(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"name": "a", "id": 1},
+		{"id": 2},
+		{"name": "c", "id": 3},
+	}
+	ca := NewCrudAdd("t", rows)
+	ca.BatchSize = 2
+	batches, err := ca.batchBuilders(mysqlDialect{}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+	sql, args, _ := batches[0].ToSql()
+	if sql != "INSERT INTO t (id,name) VALUES (?,?),(?,DEFAULT)" || len(args) != 3 {
+		t.Fatalf("unexpected sql %s %v", sql, args)
+	}
+
+	// 冲突更新时不能用默认值覆盖未提供的列
+	updates := make([]string, 1, 2)
+	updates[0] = "name"
+	ca.Mode = InsertUpsert
+	ca.UpdateColumns = updates
+	ca.ConflictColumns = []string{"id"}
+	if batches, err = ca.batchBuilders(mysqlDialect{}, rows); err != nil {
+		t.Fatal(err)
+	}
+	var sqls []string
+	for _, b := range batches {
+		sql, _, _ := b.ToSql()
+		sqls = append(sqls, sql)
+	}
+	if strings.Join(sqls, ";") != "INSERT INTO t (id,name) VALUES (?,?) ON DUPLICATE KEY UPDATE name = VALUES(name);"+
+		"INSERT IGNORE INTO t (id) VALUES (?);"+
+		"INSERT INTO t (id,name) VALUES (?,?) ON DUPLICATE KEY UPDATE name = VALUES(name)" {
+		t.Fatalf("unexpected sql %v", sqls)
+	}
+	if updates[:2][1] != "" {
+		t.Fatalf("caller update columns modified %v", updates[:2])
+	}
+}
+
+// SQLite 不支持 DEFAULT, 缺失列不同的行分开写入, 并按占位符上限拆分
+func TestBatchBuildersSqlite(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"name": "a", "tags": "x"},
+		{"name": "b"},
+		{"name": "c"},
+		{"name": "d", "tags": "y"},
+	}
+	batches, err := NewCrudAdd("product", rows).batchBuilders(sqliteDialect{}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sqls []string
+	for _, b := range batches {
+		sql, _, _ := b.ToSql()
+		sqls = append(sqls, sql)
+	}
+	if strings.Join(sqls, ";") != "INSERT INTO product (name,tags) VALUES (?,?);"+
+		"INSERT INTO product (name) VALUES (?),(?);"+
+		"INSERT INTO product (name,tags) VALUES (?,?)" {
+		t.Fatalf("unexpected sql %v", sqls)
+	}
+
+	m := newSqliteAppContext(t)
+	if _, err = m.DBAddContext(context.Background(), NewCrudAdd("product", rows)); err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	m.Context.DBPool().Select(&tags, "SELECT tags FROM product ORDER BY id")
+	if strings.Join(tags, ",") != "x,,,y" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	many := make([]map[string]interface{}, 600)
+	for i := range many {
+		many[i] = map[string]interface{}{"name": fmt.Sprintf("n%d", i), "tags": "t"}
+	}
+	ca := NewCrudAdd("product", many)
+	ca.BatchSize = 1000
+	if batches, _ = ca.batchBuilders(sqliteDialect{}, many); len(batches) != 2 {
+		t.Fatalf("expected 2 batches within placeholder limit, got %d", len(batches))
+	}
+	if _, args, _ := batches[0].ToSql(); len(args) != 998 {
+		t.Fatalf("unexpected args %d", len(args))
+	}
+	if n, err := m.DBAddContext(context.Background(), ca); err != nil || n != 600 {
+		t.Fatalf("insert %d %v", n, err)
+	}
+}
@@ -0,0 +1,318 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/ca17/go-common/log"
+)
+
+// 分页总数统计方式
+type CountMode int
+
+const (
+	// 仅首页统计总数, 兼容 Webix 分页
+	CountFirstPage CountMode = iota
+	// 每页都统计总数
+	CountAlways
+	// 统计结果按查询条件缓存 CountCacheTTL
+	CountCached
+	// 不统计总数
+	CountNone
+)
+
+// 默认总数缓存时间
+const DefaultCountCacheTTL = time.Minute
+
+// 游标分页未指定 PageSize 时的默认行数
+const DefaultCursorPageSize = 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// 游标内容, 序列化后以 base64 传递给客户端
+type cursorToken struct {
+	Value interface{} `json:"v"`
+	Tie   interface{} `json:"t,omitempty"`
+	Prev  bool        `json:"p,omitempty"`
+}
+
+// 生成游标, prev 为 true 表示向前翻页
+func EncodeCursor(value interface{}, prev bool) string {
+	return encodeCursor(cursorToken{Value: value, Prev: prev})
+}
+
+func encodeCursor(ct cursorToken) string {
+	ct.Value = cursorJSONValue(ct.Value)
+	ct.Tie = cursorJSONValue(ct.Tie)
+	bs, err := json.Marshal(ct)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+func cursorJSONValue(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05.999999")
+	}
+	return value
+}
+
+func decodeCursor(token string) (*cursorToken, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	var ct cursorToken
+	if err = decoder.Decode(&ct); err != nil || ct.Value == nil {
+		return nil, ErrInvalidCursor
+	}
+	ct.Value = cursorNumber(ct.Value)
+	ct.Tie = cursorNumber(ct.Tie)
+	return &ct, nil
+}
+
+func cursorNumber(value interface{}) interface{} {
+	if num, ok := value.(json.Number); ok {
+		if i, err := num.Int64(); err == nil {
+			return i
+		} else if f, err := num.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// 游标分页查询, 按 CursorColumn 排序, 该列不唯一时需设置 CursorTieColumn
+func (m *AppContext) dbCursorQuery(ctx context.Context, cq *CrudQuery) error {
+	cq.ResultPage = EmptyPageResult
+	cq.dialect = m.Dialect()
+	if !IsSafeIdentifier(cq.CursorColumn) {
+		return fmt.Errorf("invalid cursor column %s", cq.CursorColumn)
+	}
+	tie := cq.CursorTieColumn
+	if tie != "" && !IsSafeIdentifier(tie) {
+		return fmt.Errorf("invalid cursor tie column %s", tie)
+	}
+	if cq.PageSize == 0 {
+		cq.PageSize = DefaultCursorPageSize
+	}
+	var token *cursorToken
+	if cq.Cursor != "" {
+		var err error
+		if token, err = decodeCursor(cq.Cursor); err != nil {
+			return err
+		}
+		if (tie != "") != (token.Tie != nil) {
+			return ErrInvalidCursor
+		}
+	}
+	prev := token != nil && token.Prev
+	// 向前翻页时反向扫描, 结果再倒序
+	scanDesc := cq.CursorDesc != prev
+
+	bs, err := cq.filterBuilder(sq.Select(cq.Culumns...).From(cq.Table))
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+	if token != nil {
+		var after sq.Sqlizer = cursorAfter(cq.CursorColumn, token.Value, scanDesc)
+		if tie != "" {
+			after = sq.Or{after, sq.And{sq.Eq{cq.CursorColumn: token.Value}, cursorAfter(tie, token.Tie, scanDesc)}}
+		}
+		bs = bs.Where(after)
+	}
+	order := " ASC"
+	if scanDesc {
+		order = " DESC"
+	}
+	bs = bs.OrderBy(cq.CursorColumn + order)
+	if tie != "" {
+		bs = bs.OrderBy(tie + order)
+	}
+	bs = bs.Limit(cq.PageSize + 1)
+
+	sql, args, err := bs.ToSql()
+	if err != nil {
+		return err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sql, args)
+	}
+	err = m.selectContext(ctx, cq.Table, cq.ResultRef, sql, args...)
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+
+	rv := reflect.ValueOf(cq.ResultRef).Elem()
+	hasMore := uint64(rv.Len()) > cq.PageSize
+	if hasMore {
+		rv.Set(rv.Slice(0, int(cq.PageSize)))
+	}
+	if prev {
+		swap := reflect.Swapper(rv.Interface())
+		for i, j := 0, rv.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	result := &PageResult{Data: cq.ResultRef, Pos: int64(cq.PagePos)}
+	if rv.Len() > 0 {
+		if (!prev && hasMore) || (prev && token != nil) {
+			if result.NextCursor, err = rowCursor(rv.Index(rv.Len()-1), cq, false); err != nil {
+				return err
+			}
+		}
+		if (prev && hasMore) || (!prev && token != nil) {
+			if result.PrevCursor, err = rowCursor(rv.Index(0), cq, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	result.TotalCount, err = m.pageCount(ctx, cq, token == nil)
+	if err != nil {
+		return err
+	}
+	cq.ResultPage = result
+	return nil
+}
+
+// 翻页条件, 升序取大于游标值的行, 降序取小于游标值的行
+func cursorAfter(column string, value interface{}, desc bool) sq.Sqlizer {
+	if desc {
+		return sq.Lt{column: value}
+	}
+	return sq.Gt{column: value}
+}
+
+// 由结果行生成游标
+func rowCursor(row reflect.Value, cq *CrudQuery, prev bool) (string, error) {
+	value, err := cursorValue(row, cq.CursorColumn)
+	if err != nil {
+		return "", err
+	}
+	ct := cursorToken{Value: value, Prev: prev}
+	if cq.CursorTieColumn != "" {
+		if ct.Tie, err = cursorValue(row, cq.CursorTieColumn); err != nil {
+			return "", err
+		}
+	}
+	return encodeCursor(ct), nil
+}
+
+// 读取结果行中的游标列值, 支持结构体与 map
+func cursorValue(v reflect.Value, column string) (interface{}, error) {
+	if idx := strings.LastIndex(column, "."); idx >= 0 {
+		column = column[idx+1:]
+	}
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		tm, err := GetTableMeta(v.Type())
+		if err != nil {
+			return nil, err
+		}
+		if idx, ok := tm.fields[column]; ok {
+			return v.FieldByIndex(idx).Interface(), nil
+		}
+	case reflect.Map:
+		if mv := v.MapIndex(reflect.ValueOf(column)); mv.IsValid() {
+			return mv.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("cursor column %s not found in result", column)
+}
+
+type countCacheItem struct {
+	total  int64
+	expire time.Time
+}
+
+var (
+	countCache     sync.Map
+	countCacheSize int64
+)
+
+// 查询分页总数, first 表示当前为首页
+func (m *AppContext) pageCount(ctx context.Context, cq *CrudQuery, first bool) (int64, error) {
+	switch cq.CountMode {
+	case CountNone:
+		return 0, nil
+	case CountFirstPage:
+		if !first {
+			return 0, nil
+		}
+	}
+
+	bc, err := cq.filterBuilder(sq.Select("count(*)").From(cq.Table))
+	if err != nil {
+		return 0, err
+	}
+	sqlbc, argsbc, err := bc.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var cacheKey string
+	if cq.CountMode == CountCached {
+		cacheKey = fmt.Sprintf("%p|%s|%v", m.Context.DBPool(), sqlbc, argsbc)
+		if v, ok := countCache.Load(cacheKey); ok {
+			item := v.(countCacheItem)
+			if time.Now().Before(item.expire) {
+				return item.total, nil
+			}
+		}
+	}
+
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sqlbc, argsbc)
+	}
+	var total int64
+	err = m.getContext(ctx, cq.Table, &total, sqlbc, argsbc...)
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return 0, err
+	}
+
+	if cq.CountMode == CountCached {
+		ttl := cq.CountCacheTTL
+		if ttl <= 0 {
+			ttl = DefaultCountCacheTTL
+		}
+		_, loaded := countCache.Load(cacheKey)
+		countCache.Store(cacheKey, countCacheItem{total: total, expire: time.Now().Add(ttl)})
+		if !loaded && atomic.AddInt64(&countCacheSize, 1)%1024 == 0 {
+			purgeCountCache()
+		}
+	}
+	return total, nil
+}
+
+// 清理过期的总数缓存
+func purgeCountCache() {
+	now := time.Now()
+	countCache.Range(func(key, value interface{}) bool {
+		if now.After(value.(countCacheItem).expire) {
+			countCache.Delete(key)
+			atomic.AddInt64(&countCacheSize, -1)
+		}
+		return true
+	})
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCursorToken(t *testing.T) {
+	ct, err := decodeCursor(EncodeCursor(int64(1288834974657), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct.Value != int64(1288834974657) || !ct.Prev {
+		t.Fatalf("unexpected cursor %+v", ct)
+	}
+	if _, err = decodeCursor("not-a-cursor"); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// 插入 n 个商品, version 依次为 i/2, 每两行重复一次
+func newCursorProducts(t *testing.T, n int) *AppContext {
+	m := newSqliteAppContext(t)
+	for i := 0; i < n; i++ {
+		err := m.DBInsertContext(context.Background(), "product", map[string]interface{}{
+			"name": fmt.Sprintf("p%02d", i+1), "version": i / 2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func cursorPage(t *testing.T, m *AppContext, cq *CrudQuery) ([]int64, *PageResult) {
+	t.Helper()
+	var items []testProduct
+	cq.ResultRef = &items
+	cq.Pager = true
+	if err := m.DBQueryContext(context.Background(), cq); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Id)
+	}
+	return ids, cq.ResultPage
+}
+
+func TestCursorQueryPages(t *testing.T) {
+	m := newCursorProducts(t, 5)
+	cq := NewCrudQuery("product", []string{"id", "name", "tags", "version"}, nil)
+	cq.CursorColumn = "id"
+	cq.PageSize = 2
+
+	ids, page := cursorPage(t, m, cq)
+	if !reflect.DeepEqual(ids, []int64{1, 2}) || page.NextCursor == "" || page.PrevCursor != "" {
+		t.Fatalf("page1 %v %+v", ids, page)
+	}
+	cq.Cursor = page.NextCursor
+	ids, page = cursorPage(t, m, cq)
+	if !reflect.DeepEqual(ids, []int64{3, 4}) || page.NextCursor == "" || page.PrevCursor == "" {
+		t.Fatalf("page2 %v %+v", ids, page)
+	}
+	next := page.NextCursor
+	cq.Cursor = page.PrevCursor
+	ids, page = cursorPage(t, m, cq)
+	if !reflect.DeepEqual(ids, []int64{1, 2}) || page.NextCursor == "" || page.PrevCursor != "" {
+		t.Fatalf("prev page %v %+v", ids, page)
+	}
+	cq.Cursor = next
+	ids, page = cursorPage(t, m, cq)
+	if !reflect.DeepEqual(ids, []int64{5}) || page.NextCursor != "" || page.PrevCursor == "" {
+		t.Fatalf("last page %v %+v", ids, page)
+	}
+
+	cq.CursorDesc = true
+	cq.Cursor = ""
+	if ids, _ = cursorPage(t, m, cq); !reflect.DeepEqual(ids, []int64{5, 4}) {
+		t.Fatalf("desc page %v", ids)
+	}
+}
+
+// 游标列存在重复值时按次序列翻页, 不丢行不重复
+func TestCursorQueryTies(t *testing.T) {
+	m := newCursorProducts(t, 6)
+	cq := NewCrudQuery("product", []string{"id", "name", "tags", "version"}, nil)
+	cq.CursorColumn = "version"
+	cq.CursorTieColumn = "id"
+	cq.PageSize = 3
+
+	var all []int64
+	for {
+		ids, page := cursorPage(t, m, cq)
+		all = append(all, ids...)
+		if page.NextCursor == "" {
+			break
+		}
+		cq.Cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(all, []int64{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("unexpected rows %v", all)
+	}
+
+	// 最后一页向前翻页
+	_, page := cursorPage(t, m, cq)
+	cq.Cursor = page.PrevCursor
+	if ids, _ := cursorPage(t, m, cq); !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Fatalf("prev page with ties %v", ids)
+	}
+
+	// 游标与次序列设置不一致
+	cq.CursorTieColumn = ""
+	var items []testProduct
+	cq.ResultRef = &items
+	if err := m.DBQueryContext(context.Background(), cq); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestCursorQueryDefaultPageSize(t *testing.T) {
+	m := newCursorProducts(t, DefaultCursorPageSize+1)
+	cq := NewCrudQuery("product", []string{"id", "name", "tags", "version"}, nil)
+	cq.CursorColumn = "id"
+	ids, page := cursorPage(t, m, cq)
+	if len(ids) != DefaultCursorPageSize || page.NextCursor == "" {
+		t.Fatalf("expected default page size, got %d %+v", len(ids), page)
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// 标签匹配方式
+type TagMatch int
+
+const (
+	// 匹配任意一个标签
+	TagMatchAny TagMatch = iota
+	// 匹配全部标签
+	TagMatchAll
+)
+
+// 模糊查询方式
+type LikeMode int
+
+const (
+	// 前缀匹配 value%
+	LikePrefix LikeMode = iota
+	// 后缀匹配 %value
+	LikeSuffix
+	// 包含匹配 %value%
+	LikeContains
+	// 全文检索, mysql 为 MATCH (...) AGAINST (? IN BOOLEAN MODE), 需要全文索引
+	LikeFullText
+)
+
+// 默认标签列
+const DefaultTagColumn = "tags"
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// 判断是否为合法的列名, 支持 table.column 格式
+func IsSafeIdentifier(name string) bool {
+	return identifierRegexp.MatchString(name)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// 转义 LIKE 通配符
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// 查询使用的方言, 默认为 mysql
+func (cq *CrudQuery) getDialect() Dialect {
+	if cq.dialect != nil {
+		return cq.dialect
+	}
+	return mysqlDialect{}
+}
+
+// 标签过滤条件, 标签值使用参数绑定, 标签列不合法时返回错误
+func (cq *CrudQuery) tagFilter() (sq.Sqlizer, error) {
+	if cq.Tags == "" {
+		return nil, nil
+	}
+	column := cq.TagColumn
+	if column == "" {
+		column = DefaultTagColumn
+	}
+	if !IsSafeIdentifier(column) {
+		return nil, fmt.Errorf("invalid tag column %s", column)
+	}
+	var conds []sq.Sqlizer
+	for _, tag := range strings.Split(cq.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		conds = append(conds, cq.getDialect().TagFilter(column, tag))
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+	if cq.TagMatch == TagMatchAll {
+		return sq.And(conds), nil
+	}
+	return sq.Or(conds), nil
+}
+
+// 模糊查询条件, 查询列不合法时返回错误
+func (cq *CrudQuery) likeFilter() (sq.Sqlizer, error) {
+	if cq.LikeValue == nil || len(cq.LikeNames) == 0 {
+		return nil, nil
+	}
+	value := fmt.Sprint(cq.LikeValue)
+	if value == "" {
+		return nil, nil
+	}
+	for _, name := range cq.LikeNames {
+		if !IsSafeIdentifier(name) {
+			return nil, fmt.Errorf("invalid like column %s", name)
+		}
+	}
+
+	if cq.LikeMode == LikeFullText {
+		return cq.getDialect().FullTextFilter(cq.LikeNames, value), nil
+	}
+
+	var like string
+	switch cq.LikeMode {
+	case LikeSuffix:
+		like = "%" + EscapeLike(value)
+	case LikeContains:
+		like = "%" + EscapeLike(value) + "%"
+	default:
+		like = EscapeLike(value) + "%"
+	}
+	ormap := sq.Or{}
+	for _, name := range cq.LikeNames {
+		ormap = append(ormap, cq.getDialect().Like(name, like))
+	}
+	return ormap, nil
+}
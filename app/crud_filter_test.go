@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func filterSQL(t *testing.T, cq *CrudQuery, table string) (string, []interface{}) {
+	t.Helper()
+	b, err := cq.filterBuilder(sq.Select("id").From(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, args, err := b.ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sql, args
+}
+
+func TestTagFilter(t *testing.T) {
+	cq := &CrudQuery{Tags: `a,b") or 1=1 -- `, TagMatch: TagMatchAll}
+	sql, args := filterSQL(t, cq, "t")
+	if sql != "SELECT id FROM t WHERE (FIND_IN_SET(?, tags) AND FIND_IN_SET(?, tags))" {
+		t.Fatalf("unexpected sql %s", sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", `b") or 1=1 --`}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
+
+func TestLikeFilter(t *testing.T) {
+	cq := &CrudQuery{LikeNames: []string{"name"}, LikeValue: "50%", LikeMode: LikeContains}
+	sql, args := filterSQL(t, cq, "t")
+	if sql != "SELECT id FROM t WHERE (name LIKE ?)" || args[0] != `%50\%%` {
+		t.Fatalf("unexpected %s %v", sql, args)
+	}
+	cq.LikeMode = LikeFullText
+	cq.LikeNames = []string{"name", "remark"}
+	sql, _ = filterSQL(t, cq, "t")
+	if sql != "SELECT id FROM t WHERE MATCH (name,remark) AGAINST (? IN BOOLEAN MODE)" {
+		t.Fatalf("unexpected %s", sql)
+	}
+}
+
+// 不合法的标签列与模糊查询列返回错误, 而不是静默返回空结果
+func TestUnsafeFilterColumn(t *testing.T) {
+	m := newSqliteAppContext(t)
+	for _, cq := range []*CrudQuery{
+		{Tags: "a", TagColumn: "tags) OR (1=1"},
+		{LikeNames: []string{"name", "1=1 --"}, LikeValue: "a"},
+	} {
+		cq.Table = "product"
+		cq.Culumns = []string{"id"}
+		cq.ResultRef = &[]int64{}
+		if err := m.DBQueryContext(context.Background(), cq); err == nil {
+			t.Fatalf("expected error for %+v", cq)
+		}
+		cq.Pager = true
+		cq.PageSize = 10
+		cq.CursorColumn = "id"
+		if err := m.DBQueryContext(context.Background(), cq); err == nil {
+			t.Fatalf("expected cursor query error for %+v", cq)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ca17/go-common/log"
+)
+
+// 查询类型
+const (
+	QueryGet    = "get"
+	QuerySelect = "select"
+	QueryExec   = "exec"
+)
+
+// 一次 SQL 执行的信息
+type QueryEvent struct {
+	Op       string
+	Table    string
+	SQL      string
+	Args     []interface{}
+	Start    time.Time
+	Duration time.Duration
+	// 查询返回行数或写入影响行数
+	Rows int64
+	Err  error
+}
+
+// SQL 执行钩子, BeforeQuery 返回的 ctx 用于执行与 AfterQuery, 可用于链路追踪
+type QueryHook interface {
+	BeforeQuery(ctx context.Context, e *QueryEvent) context.Context
+	AfterQuery(ctx context.Context, e *QueryEvent)
+}
+
+// 注册 SQL 执行钩子, 按注册顺序调用
+func (m *AppContext) AddQueryHook(hook QueryHook) {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+	hooks := make([]QueryHook, len(m.hooks), len(m.hooks)+1)
+	copy(hooks, m.hooks)
+	m.hooks = append(hooks, hook)
+}
+
+func (m *AppContext) queryHooks() []QueryHook {
+	m.hooksMu.RLock()
+	defer m.hooksMu.RUnlock()
+	return m.hooks
+}
+
+// 执行 fn 并调用钩子, fn 返回行数
+func (m *AppContext) instrument(ctx context.Context, op, table, query string, args []interface{}, fn func(ctx context.Context) (int64, error)) error {
+	hooks := m.queryHooks()
+	if len(hooks) == 0 {
+		_, err := fn(ctx)
+		return err
+	}
+	if fields := strings.Fields(table); len(fields) > 0 {
+		table = fields[0]
+	}
+	e := &QueryEvent{Op: op, Table: table, SQL: query, Args: args, Start: time.Now()}
+	for _, hook := range hooks {
+		ctx = hook.BeforeQuery(ctx, e)
+	}
+	e.Rows, e.Err = fn(ctx)
+	e.Duration = time.Since(e.Start)
+	for _, hook := range hooks {
+		hook.AfterQuery(ctx, e)
+	}
+	return e.Err
+}
+
+// 慢查询日志
+type SlowQueryLogger struct {
+	Threshold time.Duration
+}
+
+func NewSlowQueryLogger(threshold time.Duration) *SlowQueryLogger {
+	return &SlowQueryLogger{Threshold: threshold}
+}
+
+func (l *SlowQueryLogger) BeforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (l *SlowQueryLogger) AfterQuery(ctx context.Context, e *QueryEvent) {
+	if e.Duration >= l.Threshold {
+		log.WarningfCtx(ctx, "slow query %s table=%s duration=%s rows=%d sql=%s args=%v",
+			e.Op, e.Table, e.Duration, e.Rows, e.SQL, e.Args)
+	}
+}
+
+// 单表执行统计
+type TableStats struct {
+	Table         string
+	Count         int64
+	Errors        int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+func (s TableStats) AvgDuration() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Count)
+}
+
+// 按表统计执行次数, 错误数与耗时
+type TableMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*TableStats
+}
+
+func NewTableMetrics() *TableMetrics {
+	return &TableMetrics{stats: make(map[string]*TableStats)}
+}
+
+func (t *TableMetrics) BeforeQuery(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (t *TableMetrics) AfterQuery(ctx context.Context, e *QueryEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.stats[e.Table]
+	if !ok {
+		s = &TableStats{Table: e.Table}
+		t.stats[e.Table] = s
+	}
+	s.Count++
+	if e.Err != nil {
+		s.Errors++
+	}
+	s.TotalDuration += e.Duration
+	if e.Duration > s.MaxDuration {
+		s.MaxDuration = e.Duration
+	}
+}
+
+// 当前统计快照, 按表名排序
+func (t *TableMetrics) Snapshot() []TableStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]TableStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+// 清空统计
+func (t *TableMetrics) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats = make(map[string]*TableStats)
+}
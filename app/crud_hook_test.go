@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTableMetricsHook(t *testing.T) {
+	m := newSqliteAppContext(t)
+	metrics := NewTableMetrics()
+	m.AddQueryHook(metrics)
+	m.AddQueryHook(NewSlowQueryLogger(0))
+
+	ctx := context.Background()
+	if err := m.DBInsertContext(ctx, "product", map[string]interface{}{"name": "apple"}); err != nil {
+		t.Fatal(err)
+	}
+	var items []testProduct
+	if err := m.DBQueryContext(ctx, NewCrudQuery("product p", []string{"id", "name"}, &items)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DBInsertContext(ctx, "product", map[string]interface{}{"name": "apple"}); err == nil {
+		t.Fatal("expected unique constraint error")
+	}
+
+	stats := metrics.Snapshot()
+	if len(stats) != 1 || stats[0].Table != "product" || stats[0].Count != 3 || stats[0].Errors != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// 数据表策略, 列名为空表示不启用对应功能
+type TablePolicy struct {
+	// 软删除列, 删除时设置为当前时间, 查询时过滤非空记录
+	DeletedAtColumn string
+	CreatedAtColumn string
+	UpdatedAtColumn string
+	// 操作人列, 取值于 WithActor 设置的用户 ID, Web 请求由 ActorMiddleware 从令牌中设置
+	CreatedByColumn string
+	UpdatedByColumn string
+}
+
+// 默认表策略, 启用软删除与全部审计列
+func DefaultTablePolicy() *TablePolicy {
+	return &TablePolicy{
+		DeletedAtColumn: "deleted_at",
+		CreatedAtColumn: "created_at",
+		UpdatedAtColumn: "updated_at",
+		CreatedByColumn: "created_by",
+		UpdatedByColumn: "updated_by",
+	}
+}
+
+// 注册表策略
+func (m *AppContext) RegisterTablePolicy(table string, policy *TablePolicy) {
+	m.policies.Store(table, policy)
+}
+
+// 获取表策略, table 可以包含别名, 如 "sys_user u"
+func (m *AppContext) GetTablePolicy(table string) *TablePolicy {
+	fields := strings.Fields(table)
+	if len(fields) == 0 {
+		return nil
+	}
+	if v, ok := m.policies.Load(fields[0]); ok {
+		return v.(*TablePolicy)
+	}
+	return nil
+}
+
+type actorContextKey struct{}
+type unscopedContextKey struct{}
+
+// 设置当前操作用户, 用于填充审计列
+func WithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (interface{}, bool) {
+	actor := ctx.Value(actorContextKey{})
+	return actor, actor != nil
+}
+
+// 忽略软删除策略, 查询包含已删除记录, 删除为物理删除
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedContextKey{}, true)
+}
+
+func isUnscoped(ctx context.Context) bool {
+	v, _ := ctx.Value(unscopedContextKey{}).(bool)
+	return v
+}
+
+// 查询时需要过滤的软删除列, 多表查询时使用表名或别名限定
+func (m *AppContext) softDeleteColumn(ctx context.Context, table string, withDeleted bool, qualified bool) string {
+	if withDeleted || isUnscoped(ctx) {
+		return ""
+	}
+	policy := m.GetTablePolicy(table)
+	if policy == nil || policy.DeletedAtColumn == "" {
+		return ""
+	}
+	if qualified {
+		fields := strings.Fields(table)
+		return fields[len(fields)-1] + "." + policy.DeletedAtColumn
+	}
+	return policy.DeletedAtColumn
+}
+
+// 新增时填充审计列, 不覆盖调用方已设置的值
+func (m *AppContext) fillInsertAudit(ctx context.Context, table string, vals map[string]interface{}) map[string]interface{} {
+	policy := m.GetTablePolicy(table)
+	if policy == nil {
+		return vals
+	}
+	now := time.Now()
+	actor, hasActor := ActorFromContext(ctx)
+	result := make(map[string]interface{}, len(vals)+4)
+	for k, v := range vals {
+		result[k] = v
+	}
+	setDefault := func(col string, val interface{}) {
+		if col == "" {
+			return
+		}
+		if _, ok := result[col]; !ok {
+			result[col] = val
+		}
+	}
+	setDefault(policy.CreatedAtColumn, now)
+	setDefault(policy.UpdatedAtColumn, now)
+	if hasActor {
+		setDefault(policy.CreatedByColumn, actor)
+		setDefault(policy.UpdatedByColumn, actor)
+	}
+	return result
+}
+
+// 更新时填充审计列
+func (m *AppContext) fillUpdateAudit(ctx context.Context, table string, b sq.UpdateBuilder, vals map[string]interface{}) sq.UpdateBuilder {
+	policy := m.GetTablePolicy(table)
+	if policy == nil {
+		return b
+	}
+	if _, ok := vals[policy.UpdatedAtColumn]; policy.UpdatedAtColumn != "" && !ok {
+		b = b.Set(policy.UpdatedAtColumn, time.Now())
+	}
+	if actor, hasActor := ActorFromContext(ctx); hasActor {
+		if _, ok := vals[policy.UpdatedByColumn]; policy.UpdatedByColumn != "" && !ok {
+			b = b.Set(policy.UpdatedByColumn, actor)
+		}
+	}
+	return b
+}
+
+// 软删除语句, 表未启用软删除时返回 nil
+func (m *AppContext) softDeleteBuilder(ctx context.Context, table string, filter map[string]interface{}) *sq.UpdateBuilder {
+	if isUnscoped(ctx) {
+		return nil
+	}
+	policy := m.GetTablePolicy(table)
+	if policy == nil || policy.DeletedAtColumn == "" {
+		return nil
+	}
+	b := sq.Update(table).
+		Set(policy.DeletedAtColumn, time.Now()).
+		Where(filter).
+		Where(sq.Eq{policy.DeletedAtColumn: nil})
+	b = m.fillUpdateAudit(ctx, table, b, nil)
+	return &b
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/auth"
+	"github.com/ca17/go-common/conf"
+)
+
+func TestTablePolicy(t *testing.T) {
+	m := &AppContext{}
+	m.RegisterTablePolicy("sys_user", DefaultTablePolicy())
+
+	ctx := WithActor(context.Background(), int64(1001))
+	vals := m.fillInsertAudit(ctx, "sys_user", map[string]interface{}{"name": "a", "created_by": int64(1)})
+	if vals["created_by"] != int64(1) || vals["updated_by"] != int64(1001) || vals["created_at"] == nil {
+		t.Fatalf("unexpected audit values %v", vals)
+	}
+
+	if col := m.softDeleteColumn(ctx, "sys_user u", false, true); col != "u.deleted_at" {
+		t.Fatalf("unexpected soft delete column %s", col)
+	}
+	if col := m.softDeleteColumn(Unscoped(ctx), "sys_user", false, false); col != "" {
+		t.Fatalf("unscoped should skip soft delete, got %s", col)
+	}
+	if m.softDeleteBuilder(ctx, "sys_role", map[string]interface{}{"id": 1}) != nil {
+		t.Fatal("table without policy should be hard deleted")
+	}
+}
+
+// 认证后的请求使用令牌中的用户 ID 填充审计列
+func TestServerActorFromClaims(t *testing.T) {
+	m := newSqliteAppContext(t)
+	m.Context.DBPool().MustExec(`CREATE TABLE audit_item (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		created_at DATETIME,
+		updated_at DATETIME,
+		created_by TEXT,
+		updated_by TEXT,
+		deleted_at DATETIME
+	)`)
+	m.RegisterTablePolicy("audit_item", DefaultTablePolicy())
+	manager := auth.NewManager(auth.NewKeySet(auth.NewHMACKey("k1", []byte("secret"))), nil, auth.Config{})
+	m.Set(AuthManager, manager)
+	s := NewServer(&testAppConfig{web: conf.WebConfig{Secret: "secret"}, prod: true}, m, nil)
+	s.Echo.POST("/items", func(c echo.Context) error {
+		return m.DBInsertContext(c.Request().Context(), "audit_item", map[string]interface{}{"name": "a"})
+	})
+
+	claims := auth.Claims{}
+	claims.Subject = "42"
+	pair, err := manager.Issue(context.Background(), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+pair.AccessToken)
+	rec := httptest.NewRecorder()
+	s.Echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d %s", rec.Code, rec.Body.String())
+	}
+	var createdBy, updatedBy string
+	row := m.Context.DBPool().QueryRow("SELECT created_by, updated_by FROM audit_item")
+	if err = row.Scan(&createdBy, &updatedBy); err != nil || createdBy != "42" || updatedBy != "42" {
+		t.Fatalf("unexpected actor %q %q %v", createdBy, updatedBy, err)
+	}
+}
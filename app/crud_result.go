@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/ca17/go-common/log"
+)
+
+// 乐观锁版本冲突
+var ErrStaleVersion = errors.New("stale version")
+
+// SQL 执行结果
+type ExecResult struct {
+	// 自增 ID, 仅 HasLastInsertId 为 true 时有效
+	LastInsertId int64
+	// 驱动支持 LastInsertId 时为 true, postgres 需使用 DBInsertReturningResult
+	HasLastInsertId bool
+	RowsAffected    int64
+}
+
+// CRUD 增加数据对象, 返回自增 ID 与影响行数
+func (m *AppContext) DBInsertResult(ctx context.Context, table string, vals map[string]interface{}) (*ExecResult, error) {
+	return m.DBInsertWithTxResult(ctx, nil, table, vals)
+}
+
+func (m *AppContext) DBInsertWithTxResult(ctx context.Context, tx *sql.Tx, table string, vals map[string]interface{}) (*ExecResult, error) {
+	sql, args, err := m.insertBuilder(ctx, table, vals).ToSql()
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return nil, err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sql, args)
+	}
+	return m.execResult(ctx, tx, table, sql, args...)
+}
+
+// CRUD 增加数据对象并返回主键 pk 的值, 驱动不支持 LastInsertId 时使用 INSERT ... RETURNING pk
+func (m *AppContext) DBInsertReturningResult(ctx context.Context, tx *sql.Tx, table string, pk string, vals map[string]interface{}) (*ExecResult, error) {
+	if !m.Dialect().ReturningID() {
+		return m.DBInsertWithTxResult(ctx, tx, table, vals)
+	}
+	if !IsSafeIdentifier(pk) {
+		return nil, fmt.Errorf("invalid primary key column %s", pk)
+	}
+	query, args, err := m.insertBuilder(ctx, table, vals).Suffix("RETURNING " + pk).ToSql()
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return nil, err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, query, args)
+	}
+	query = m.rebind(query)
+	result := &ExecResult{}
+	err = m.instrument(ctx, QueryExec, table, query, args, func(ctx context.Context) (int64, error) {
+		if err := m.execer(ctx, tx).QueryRowContext(ctx, query, args...).Scan(&result.LastInsertId); err != nil {
+			return 0, err
+		}
+		result.HasLastInsertId = true
+		result.RowsAffected = 1
+		return 1, nil
+	})
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *AppContext) insertBuilder(ctx context.Context, table string, vals map[string]interface{}) sq.InsertBuilder {
+	vals = m.fillInsertAudit(ctx, table, vals)
+	cols := sortedColumns(vals)
+	values := make([]interface{}, 0, len(cols))
+	for _, col := range cols {
+		values = append(values, vals[col])
+	}
+	return sq.Insert(table).Columns(cols...).Values(values...)
+}
+
+// CRUD 数据更新, 返回影响行数.
+// 设置 VersionColumn 时未匹配到旧版本返回 ErrStaleVersion
+func (m *AppContext) DBUpdateResult(ctx context.Context, cu *CrudUpdate) (*ExecResult, error) {
+	b := sq.Update(cu.Table).SetMap(cu.Vals)
+	b = m.fillUpdateAudit(ctx, cu.Table, b, cu.Vals)
+	if cu.VersionColumn != "" {
+		if !IsSafeIdentifier(cu.VersionColumn) {
+			return nil, fmt.Errorf("invalid version column %s", cu.VersionColumn)
+		}
+		b = b.Set(cu.VersionColumn, sq.Expr(cu.VersionColumn+" + 1")).
+			Where(sq.Eq{cu.VersionColumn: cu.Version})
+	}
+	if cu.Eq != nil {
+		b = b.Where(cu.Eq)
+	}
+	if cu.LtOrEq != nil {
+		b = b.Where(cu.LtOrEq)
+	}
+	if cu.GtOrEq != nil {
+		b = b.Where(cu.GtOrEq)
+	}
+	sql, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, sql, args)
+	}
+	r, err := m.execResult(ctx, cu.tx, cu.Table, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if cu.VersionColumn != "" && r.RowsAffected == 0 {
+		return r, fmt.Errorf("%w: %s %s=%v", ErrStaleVersion, cu.Table, cu.VersionColumn, cu.Version)
+	}
+	return r, nil
+}
+
+// 执行 SQL 并返回执行结果
+func (m *AppContext) execResult(ctx context.Context, tx *sql.Tx, table string, query string, args ...interface{}) (*ExecResult, error) {
+	query = m.rebind(query)
+	result := &ExecResult{}
+	err := m.instrument(ctx, QueryExec, table, query, args, func(ctx context.Context) (int64, error) {
+		r, err := m.execer(ctx, tx).ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		// 部分驱动不支持 LastInsertId, 此时 HasLastInsertId 为 false
+		if id, err := r.LastInsertId(); err == nil {
+			result.LastInsertId, result.HasLastInsertId = id, true
+		}
+		result.RowsAffected, err = r.RowsAffected()
+		return result.RowsAffected, err
+	})
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return nil, err
+	}
+	return result, nil
+}
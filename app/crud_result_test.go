@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// 使用 RETURNING 获取主键的 sqlite 方言, 模拟 postgres
+type returningSqliteDialect struct{ sqliteDialect }
+
+func (returningSqliteDialect) ReturningID() bool { return true }
+
+func TestExecResult(t *testing.T) {
+	ctx := context.Background()
+	m := newSqliteAppContext(t)
+
+	r, err := m.DBInsertResult(ctx, "product", map[string]interface{}{"name": "a"})
+	if err != nil || !r.HasLastInsertId || r.LastInsertId != 1 || r.RowsAffected != 1 {
+		t.Fatalf("insert %+v %v", r, err)
+	}
+
+	RegisterDialect(returningSqliteDialect{})
+	defer RegisterDialect(sqliteDialect{})
+	r, err = m.DBInsertReturningResult(ctx, nil, "product", "id", map[string]interface{}{"name": "b"})
+	if err != nil || !r.HasLastInsertId || r.LastInsertId != 2 || r.RowsAffected != 1 {
+		t.Fatalf("insert returning %+v %v", r, err)
+	}
+	if _, err = m.DBInsertReturningResult(ctx, nil, "product", "id; --", map[string]interface{}{"name": "c"}); err == nil {
+		t.Fatal("expected invalid primary key error")
+	}
+	RegisterDialect(sqliteDialect{})
+
+	r, err = m.DBUpdateResult(ctx, NewCrudUpdate("product", map[string]interface{}{"tags": "x"}, map[string]interface{}{}))
+	if err != nil || r.RowsAffected != 2 {
+		t.Fatalf("update all %+v %v", r, err)
+	}
+	r, err = m.DBUpdateResult(ctx, NewCrudUpdate("product", map[string]interface{}{"tags": "y"}, map[string]interface{}{"id": 3}))
+	if err != nil || r.RowsAffected != 0 {
+		t.Fatalf("update missing %+v %v", r, err)
+	}
+
+	cu := NewCrudUpdate("product", map[string]interface{}{"tags": "z"}, map[string]interface{}{"id": 1})
+	cu.VersionColumn = "version"
+	cu.Version = 0
+	if r, err = m.DBUpdateResult(ctx, cu); err != nil || r.RowsAffected != 1 {
+		t.Fatalf("versioned update %+v %v", r, err)
+	}
+	// 版本已递增, 再次使用旧版本更新失败
+	if r, err = m.DBUpdateResult(ctx, cu); !errors.Is(err, ErrStaleVersion) || r.RowsAffected != 0 {
+		t.Fatalf("expected stale version, got %+v %v", r, err)
+	}
+	cu.Version = 1
+	if r, err = m.DBUpdateResult(ctx, cu); err != nil || r.RowsAffected != 1 {
+		t.Fatalf("update with current version %+v %v", r, err)
+	}
+}
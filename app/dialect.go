@@ -0,0 +1,241 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/ca17/go-common/conf"
+	"github.com/ca17/go-common/log"
+)
+
+const (
+	DialectMysql    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSqlite   = "sqlite"
+)
+
+// SQL 方言, 屏蔽不同数据库的驱动与语法差异.
+// 除 mysql 外, 应用需要自行导入对应驱动, 如 github.com/lib/pq, github.com/mattn/go-sqlite3
+type Dialect interface {
+	Name() string
+	DriverName() string
+	DSN(config *conf.DBConfig) string
+	PlaceholderFormat() sq.PlaceholderFormat
+	// 逗号分隔的标签列匹配单个标签
+	TagFilter(column string, tag string) sq.Sqlizer
+	// LIKE 匹配, pattern 中的通配符已使用 \ 转义
+	Like(column string, pattern string) sq.Sqlizer
+	// 多列全文检索
+	FullTextFilter(columns []string, value string) sq.Sqlizer
+	TruncateSQL(table string) string
+	// 批量写入时缺失列的取值, 返回 nil 表示不支持, 缺失列不同的行分开写入
+	MissingValue() interface{}
+	// 单条语句允许的最大占位符数量
+	MaxPlaceholders() int
+	// 驱动不支持 LastInsertId, 需使用 INSERT ... RETURNING 获取主键
+	ReturningID() bool
+	InsertIgnore(b sq.InsertBuilder) sq.InsertBuilder
+	// 冲突更新子句, conflicts 为唯一键列
+	UpsertSuffix(conflicts []string, updates []string) (string, error)
+}
+
+var (
+	dialects       sync.Map
+	unknownDrivers sync.Map
+)
+
+// 注册方言, 同时按名称与驱动名索引
+func RegisterDialect(d Dialect) {
+	dialects.Store(d.Name(), d)
+	dialects.Store(d.DriverName(), d)
+}
+
+// 按名称或驱动名获取方言, 名称为空时返回 mysql
+func GetDialect(name string) (Dialect, error) {
+	if name == "" {
+		name = DialectMysql
+	}
+	if v, ok := dialects.Load(name); ok {
+		return v.(Dialect), nil
+	}
+	return nil, fmt.Errorf("unsupported database dialect %s", name)
+}
+
+func init() {
+	RegisterDialect(mysqlDialect{})
+	RegisterDialect(postgresDialect{})
+	RegisterDialect(sqliteDialect{})
+}
+
+// 当前连接池使用的方言
+// 未注册的驱动回退为 mysql, 每个驱动名仅记录一次错误日志
+func (m *AppContext) Dialect() Dialect {
+	driver := m.Context.DBPool().DriverName()
+	d, err := GetDialect(driver)
+	if err != nil {
+		if _, warned := unknownDrivers.LoadOrStore(driver, true); !warned {
+			log.Errorf("%s, fallback to mysql, use RegisterDialect to register it", err.Error())
+		}
+		d, _ = GetDialect(DialectMysql)
+	}
+	return d
+}
+
+// 按方言替换 ? 占位符
+func (m *AppContext) rebind(query string) string {
+	query, _ = m.Dialect().PlaceholderFormat().ReplacePlaceholders(query)
+	return query
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string       { return DialectMysql }
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+func (mysqlDialect) DSN(config *conf.DBConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		config.User,
+		config.Passwd,
+		config.Host,
+		config.Port,
+		config.Name)
+}
+
+func (mysqlDialect) PlaceholderFormat() sq.PlaceholderFormat { return sq.Question }
+
+func (mysqlDialect) TagFilter(column string, tag string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("FIND_IN_SET(?, %s)", column), tag)
+}
+
+func (mysqlDialect) Like(column string, pattern string) sq.Sqlizer {
+	return sq.Like{column: pattern}
+}
+
+func (mysqlDialect) FullTextFilter(columns []string, value string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("MATCH (%s) AGAINST (? IN BOOLEAN MODE)", strings.Join(columns, ",")), value)
+}
+
+func (mysqlDialect) TruncateSQL(table string) string { return "TRUNCATE TABLE " + table }
+
+func (mysqlDialect) MissingValue() interface{} { return sq.Expr("DEFAULT") }
+
+func (mysqlDialect) MaxPlaceholders() int { return 65535 }
+
+func (mysqlDialect) ReturningID() bool { return false }
+
+func (mysqlDialect) InsertIgnore(b sq.InsertBuilder) sq.InsertBuilder { return b.Options("IGNORE") }
+
+func (mysqlDialect) UpsertSuffix(conflicts []string, updates []string) (string, error) {
+	sets := make([]string, 0, len(updates))
+	for _, col := range updates {
+		sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string       { return DialectPostgres }
+func (postgresDialect) DriverName() string { return "postgres" }
+
+func (postgresDialect) DSN(config *conf.DBConfig) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		config.Host,
+		config.Port,
+		config.User,
+		config.Passwd,
+		config.Name)
+}
+
+func (postgresDialect) PlaceholderFormat() sq.PlaceholderFormat { return sq.Dollar }
+
+func (postgresDialect) TagFilter(column string, tag string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("? = ANY(string_to_array(%s, ','))", column), tag)
+}
+
+func (postgresDialect) Like(column string, pattern string) sq.Sqlizer {
+	return sq.Like{column: pattern}
+}
+
+func (postgresDialect) FullTextFilter(columns []string, value string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("to_tsvector(concat_ws(' ', %s)) @@ plainto_tsquery(?)", strings.Join(columns, ", ")), value)
+}
+
+func (postgresDialect) TruncateSQL(table string) string { return "TRUNCATE TABLE " + table }
+
+func (postgresDialect) MissingValue() interface{} { return sq.Expr("DEFAULT") }
+
+func (postgresDialect) MaxPlaceholders() int { return 65535 }
+
+func (postgresDialect) ReturningID() bool { return true }
+
+func (postgresDialect) InsertIgnore(b sq.InsertBuilder) sq.InsertBuilder {
+	return b.Suffix("ON CONFLICT DO NOTHING")
+}
+
+func (postgresDialect) UpsertSuffix(conflicts []string, updates []string) (string, error) {
+	return conflictUpsertSuffix(conflicts, updates)
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string       { return DialectSqlite }
+func (sqliteDialect) DriverName() string { return "sqlite3" }
+
+// Name 为数据库文件路径, 或 :memory: 使用内存数据库.
+// 内存数据库每个连接独立, 需设置 max_conn 与 max_idle 为 1
+func (sqliteDialect) DSN(config *conf.DBConfig) string {
+	return config.Name
+}
+
+func (sqliteDialect) PlaceholderFormat() sq.PlaceholderFormat { return sq.Question }
+
+func (sqliteDialect) TagFilter(column string, tag string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("instr(',' || %s || ',', ',' || ? || ',') > 0", column), tag)
+}
+
+// SQLite 没有默认转义字符, 需显式指定
+func (sqliteDialect) Like(column string, pattern string) sq.Sqlizer {
+	return sq.Expr(column+` LIKE ? ESCAPE '\'`, pattern)
+}
+
+// SQLite 未启用 FTS 时退化为包含匹配
+func (d sqliteDialect) FullTextFilter(columns []string, value string) sq.Sqlizer {
+	like := "%" + EscapeLike(value) + "%"
+	ormap := sq.Or{}
+	for _, col := range columns {
+		ormap = append(ormap, d.Like(col, like))
+	}
+	return ormap
+}
+
+func (sqliteDialect) TruncateSQL(table string) string { return "DELETE FROM " + table }
+
+// SQLite 不支持 VALUES 中使用 DEFAULT
+func (sqliteDialect) MissingValue() interface{} { return nil }
+
+// SQLite 3.32 起默认上限为 32766, 兼容旧版本按 999 计算
+func (sqliteDialect) MaxPlaceholders() int { return 999 }
+
+func (sqliteDialect) ReturningID() bool { return false }
+
+func (sqliteDialect) InsertIgnore(b sq.InsertBuilder) sq.InsertBuilder { return b.Options("OR IGNORE") }
+
+func (sqliteDialect) UpsertSuffix(conflicts []string, updates []string) (string, error) {
+	return conflictUpsertSuffix(conflicts, updates)
+}
+
+// ON CONFLICT 语法, postgres 与 sqlite 通用
+func conflictUpsertSuffix(conflicts []string, updates []string) (string, error) {
+	if len(conflicts) == 0 {
+		return "", fmt.Errorf("upsert requires conflict columns")
+	}
+	sets := make([]string, 0, len(updates))
+	for _, col := range updates {
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflicts, ", "), strings.Join(sets, ", ")), nil
+}
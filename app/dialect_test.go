@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+
+	"github.com/ca17/go-common/conf"
+)
+
+type testContextManager struct {
+	db     *sqlx.DB
+	values map[string]interface{}
+}
+
+func (t *testContextManager) DBPool() *sqlx.DB             { return t.db }
+func (t *testContextManager) MongoDb() *mongo.Client       { return nil }
+func (t *testContextManager) GrpConn() *grpc.ClientConn    { return nil }
+func (t *testContextManager) GetAppConfig() conf.AppConfig { return nil }
+func (t *testContextManager) Get(key string) (interface{}, bool) {
+	v, ok := t.values[key]
+	return v, ok
+}
+func (t *testContextManager) Set(key string, val interface{}) { t.values[key] = val }
+
+type testProduct struct {
+	Id      int64  `db:"id"`
+	Name    string `db:"name"`
+	Tags    string `db:"tags"`
+	Version int64  `db:"version"`
+}
+
+func (testProduct) TableName() string {
+	return "product"
+}
+
+func newSqliteAppContext(t *testing.T) *AppContext {
+	db := GetDatabase(&conf.DBConfig{Type: DialectSqlite, Name: ":memory:", MaxConn: 1, MaxIdle: 1})
+	t.Cleanup(func() { db.Close() })
+	db.MustExec(`CREATE TABLE product (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		tags TEXT NOT NULL DEFAULT '',
+		version INTEGER NOT NULL DEFAULT 0,
+		deleted_at DATETIME
+	)`)
+	return NewAppContext(&testContextManager{db: db, values: map[string]interface{}{}})
+}
+
+func TestSqliteCrud(t *testing.T) {
+	m := newSqliteAppContext(t)
+	ctx := context.Background()
+	repo, err := NewRepository[testProduct](m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"apple", "banana", "cherry"} {
+		if err := repo.Insert(ctx, testProduct{Name: name, Tags: "fruit," + name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cq := repo.Query()
+	cq.Tags = "banana,cherry"
+	items, err := repo.List(ctx, cq)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("tag filter %v %v", items, err)
+	}
+
+	cq = repo.Query()
+	cq.PageSize = 2
+	cq.CursorColumn = "id"
+	page1, result, err := repo.Page(ctx, cq)
+	if err != nil || len(page1) != 2 || result.NextCursor == "" || result.TotalCount != 3 {
+		t.Fatalf("cursor page1 %v %+v %v", page1, result, err)
+	}
+	cq.Cursor = result.NextCursor
+	page2, result, err := repo.Page(ctx, cq)
+	if err != nil || len(page2) != 1 || page2[0].Name != "cherry" || result.PrevCursor == "" {
+		t.Fatalf("cursor page2 %v %+v %v", page2, result, err)
+	}
+
+	ca := NewCrudAdd("product", []map[string]interface{}{{"name": "apple", "tags": "red"}})
+	ca.Mode = InsertUpsert
+	ca.ConflictColumns = []string{"name"}
+	ca.UpdateColumns = []string{"tags"}
+	if _, err = m.DBAddContext(ctx, ca); err != nil {
+		t.Fatal(err)
+	}
+	apple, err := repo.Get(ctx, map[string]interface{}{"name": "apple"})
+	if err != nil || apple.Tags != "red" {
+		t.Fatalf("upsert %+v %v", apple, err)
+	}
+
+	cu := NewCrudUpdate("product", map[string]interface{}{"tags": "green"}, map[string]interface{}{"id": apple.Id})
+	cu.VersionColumn = "version"
+	cu.Version = apple.Version + 1
+	if _, err = m.DBUpdateResult(ctx, cu); !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("expected stale version, got %v", err)
+	}
+
+	err = m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if _, err := m.DBDeleteWithFilterContext(ctx, "product", map[string]interface{}{"name": "banana"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("expected rollback error")
+	}
+	if items, _ = repo.List(ctx, nil); len(items) != 3 {
+		t.Fatalf("delete should be rolled back, got %v", items)
+	}
+
+	m.RegisterTablePolicy("product", &TablePolicy{DeletedAtColumn: "deleted_at"})
+	if n, err := repo.Delete(ctx, []string{"1"}); err != nil || n != 1 {
+		t.Fatalf("soft delete %d %v", n, err)
+	}
+	if items, _ = repo.List(ctx, nil); len(items) != 2 {
+		t.Fatalf("soft deleted row should be hidden, got %v", items)
+	}
+	if items, _ = repo.List(Unscoped(ctx), nil); len(items) != 3 {
+		t.Fatalf("unscoped should include deleted rows, got %v", items)
+	}
+
+	if err = m.DBTrucateContext(ctx, "product"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialectUnknownDriver(t *testing.T) {
+	m := newSqliteAppContext(t)
+	m2 := NewAppContext(&testContextManager{db: sqlx.NewDb(m.Context.DBPool().DB, "wrapped-sqlite")})
+	if d := m2.Dialect(); d.Name() != DialectMysql {
+		t.Fatalf("expected mysql fallback, got %s", d.Name())
+	}
+	if _, ok := unknownDrivers.Load("wrapped-sqlite"); !ok {
+		t.Fatal("expected unknown driver to be recorded")
+	}
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/log"
+)
+
+// 错误分类
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindUnauthorized
+	KindForbidden
+)
+
+// 业务错误码, 默认与 HTTP 状态码一致, 业务可通过 WithCode 指定更细的错误码
+const (
+	CodeSuccess      = 0
+	CodeValidation   = 400
+	CodeUnauthorized = 401
+	CodeForbidden    = 403
+	CodeNotFound     = 404
+	CodeConflict     = 409
+	CodeInternal     = 500
+	// RestError 使用的通用错误码
+	CodeGeneric = 9999
+)
+
+var kindStatus = map[ErrorKind]int{
+	KindInternal:     http.StatusInternalServerError,
+	KindValidation:   http.StatusBadRequest,
+	KindNotFound:     http.StatusNotFound,
+	KindConflict:     http.StatusConflict,
+	KindUnauthorized: http.StatusUnauthorized,
+	KindForbidden:    http.StatusForbidden,
+}
+
+var kindNames = map[ErrorKind]string{
+	KindInternal:     "internal",
+	KindValidation:   "validation",
+	KindNotFound:     "not_found",
+	KindConflict:     "conflict",
+	KindUnauthorized: "unauthorized",
+	KindForbidden:    "forbidden",
+}
+
+func (k ErrorKind) String() string {
+	return kindNames[k]
+}
+
+// 对应的 HTTP 状态码
+func (k ErrorKind) Status() int {
+	if s, ok := kindStatus[k]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// 按 HTTP 状态码归类
+func kindOfStatus(status int) ErrorKind {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return KindValidation
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusConflict:
+		return KindConflict
+	case http.StatusUnauthorized:
+		return KindUnauthorized
+	case http.StatusForbidden:
+		return KindForbidden
+	}
+	return KindInternal
+}
+
+// 应用错误, Msg 返回给客户端, Internal 仅记录日志, 调试模式下才会返回
+type AppError struct {
+	Kind     ErrorKind
+	Code     int
+	Msg      string
+	Data     interface{}
+	Internal error
+	// 非标准状态码, 如 429, 为 0 时按 Kind 确定
+	status int
+}
+
+func NewAppError(kind ErrorKind, msg string) *AppError {
+	return &AppError{Kind: kind, Code: kind.Status(), Msg: msg}
+}
+
+func NewValidationError(msg string) *AppError {
+	return NewAppError(KindValidation, msg)
+}
+
+func NewNotFoundError(msg string) *AppError {
+	return NewAppError(KindNotFound, msg)
+}
+
+func NewConflictError(msg string) *AppError {
+	return NewAppError(KindConflict, msg)
+}
+
+func NewUnauthorizedError(msg string) *AppError {
+	return NewAppError(KindUnauthorized, msg)
+}
+
+func NewForbiddenError(msg string) *AppError {
+	return NewAppError(KindForbidden, msg)
+}
+
+// 内部错误, 客户端只会看到通用提示
+func NewInternalError(err error) *AppError {
+	return &AppError{Kind: KindInternal, Code: CodeInternal, Msg: http.StatusText(http.StatusInternalServerError), Internal: err}
+}
+
+func (e *AppError) Error() string {
+	if e.Internal != nil {
+		return fmt.Sprintf("%s %d: %s: %v", e.Kind, e.Code, e.Msg, e.Internal)
+	}
+	return fmt.Sprintf("%s %d: %s", e.Kind, e.Code, e.Msg)
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Internal
+}
+
+// HTTP 状态码
+func (e *AppError) Status() int {
+	if e.status > 0 {
+		return e.status
+	}
+	return e.Kind.Status()
+}
+
+func (e *AppError) WithCode(code int) *AppError {
+	e.Code = code
+	return e
+}
+
+func (e *AppError) WithData(data interface{}) *AppError {
+	e.Data = data
+	return e
+}
+
+func (e *AppError) Wrap(err error) *AppError {
+	e.Internal = err
+	return e
+}
+
+// 转换为 RestResult, debug 为 false 时隐藏内部错误
+func (e *AppError) RestResult(debug bool) *RestResult {
+	msg := e.Msg
+	if debug && e.Internal != nil {
+		msg = msg + ": " + e.Internal.Error()
+	}
+	return &RestResult{Code: e.Code, Msgtype: "error", Msg: msg, Data: e.Data}
+}
+
+// 将任意错误归类为 AppError, 未归类的错误视为内部错误
+func AsAppError(err error) *AppError {
+	var ae *AppError
+	if errors.As(err, &ae) {
+		return ae
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return fromStatus(he.Code, he.Message, he.Internal)
+	}
+	var lhe *HTTPError
+	if errors.As(err, &lhe) {
+		return fromStatus(lhe.Code, lhe.Message, lhe.Internal)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NewNotFoundError(http.StatusText(http.StatusNotFound)).Wrap(err)
+	}
+	return NewInternalError(err)
+}
+
+func fromStatus(status int, message interface{}, internal error) *AppError {
+	kind := kindOfStatus(status)
+	ae := &AppError{Kind: kind, Code: status, Msg: fmt.Sprint(message), Internal: internal}
+	if kind.Status() != status {
+		ae.status = status
+	}
+	if kind == KindInternal && status >= http.StatusInternalServerError {
+		// 服务端错误的消息可能包含内部细节
+		if internal == nil {
+			ae.Internal = errors.New(ae.Msg)
+		}
+		ae.Msg = http.StatusText(status)
+	}
+	return ae
+}
+
+// 统一错误处理, 按 Accept 头返回 JSON RestResult 或 err404/err500 页面,
+// 非调试模式下不返回内部错误详情
+func HTTPErrorHandler(debug bool) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+		ae := AsAppError(err)
+		status := ae.Status()
+		if status >= http.StatusInternalServerError {
+			req := c.Request()
+			log.ErrorfCtx(req.Context(), "%s %s error %v", req.Method, req.URL.Path, err)
+		}
+		result := ae.RestResult(debug)
+
+		var rerr error
+		switch {
+		case c.Request().Method == http.MethodHead:
+			rerr = c.NoContent(status)
+		case acceptsHTML(c.Request()) && c.Echo().Renderer != nil:
+			name := "err500"
+			if status == http.StatusNotFound {
+				name = "err404"
+			}
+			rerr = c.Render(status, name, map[string]string{"message": result.Msg})
+			if rerr != nil && !c.Response().Committed {
+				rerr = c.JSON(status, result)
+			}
+		default:
+			rerr = c.JSON(status, result)
+		}
+		if rerr != nil {
+			log.ErrorfCtx(c.Request().Context(), "render error response %v", rerr)
+		}
+	}
+}
+
+// 浏览器请求优先返回页面
+func acceptsHTML(r *http.Request) bool {
+	accept := r.Header.Get(echo.HeaderAccept)
+	return strings.Contains(accept, echo.MIMETextHTML) && !strings.Contains(accept, echo.MIMEApplicationJSON)
+}
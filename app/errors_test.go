@@ -0,0 +1,102 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type errTemplate struct{}
+
+func (errTemplate) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	_, err := fmt.Fprintf(w, "%s:%s", name, data.(map[string]string)["message"])
+	return err
+}
+
+func TestAsAppError(t *testing.T) {
+	cases := []struct {
+		err    error
+		kind   ErrorKind
+		status int
+	}{
+		{NewConflictError("exists"), KindConflict, http.StatusConflict},
+		{fmt.Errorf("load: %w", sql.ErrNoRows), KindNotFound, http.StatusNotFound},
+		{echo.ErrForbidden, KindForbidden, http.StatusForbidden},
+		{NewHTTPError(http.StatusUnauthorized), KindUnauthorized, http.StatusUnauthorized},
+		{echo.NewHTTPError(http.StatusTooManyRequests), KindInternal, http.StatusTooManyRequests},
+		{errors.New("boom"), KindInternal, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		ae := AsAppError(tc.err)
+		if ae.Kind != tc.kind || ae.Status() != tc.status {
+			t.Errorf("%v: kind %s status %d", tc.err, ae.Kind, ae.Status())
+		}
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	e.Renderer = errTemplate{}
+	e.GET("/conflict", func(c echo.Context) error {
+		return NewConflictError("名称已存在").WithCode(40901).WithData("name")
+	})
+	e.GET("/internal", func(c echo.Context) error {
+		return errors.New("dial tcp 10.0.0.1: refused")
+	})
+
+	do := func(path string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	e.HTTPErrorHandler = HTTPErrorHandler(false)
+	rec := do("/conflict", echo.MIMEApplicationJSON)
+	var result RestResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusConflict || result.Code != 40901 || result.Msg != "名称已存在" || result.Data != "name" {
+		t.Fatalf("conflict %d %+v", rec.Code, result)
+	}
+	rec = do("/internal", echo.MIMEApplicationJSON)
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "10.0.0.1") {
+		t.Fatalf("internal detail leaked %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do("/missing", "text/html,application/xhtml+xml")
+	if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Body.String(), "err404:") {
+		t.Fatalf("html 404 %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do("/internal", "text/html")
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "err500:Internal Server Error" {
+		t.Fatalf("html 500 %d %s", rec.Code, rec.Body.String())
+	}
+
+	e.HTTPErrorHandler = HTTPErrorHandler(true)
+	rec = do("/internal", echo.MIMEApplicationJSON)
+	if !strings.Contains(rec.Body.String(), "10.0.0.1") {
+		t.Fatalf("debug should show detail %s", rec.Body.String())
+	}
+}
+
+func TestGoInternalErrPage(t *testing.T) {
+	e := echo.New()
+	e.Renderer = errTemplate{}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	h := &HttpHandler{}
+	if err := h.GoInternalErrPage(c, 42); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.String() != "err500:42" {
+		t.Fatalf("body %s", rec.Body.String())
+	}
+}
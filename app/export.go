@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/excel"
+	"github.com/ca17/go-common/log"
+)
+
+// 导出时每写出多少行刷新一次响应
+const exportFlushRows = 1000
+
+// 逐行读取查询结果, 不加载全部数据, 忽略分页参数, 支持 context 取消
+func (m *AppContext) DBQueryEach(ctx context.Context, cq *CrudQuery, fn func(rows *sqlx.Rows) error) error {
+	m.prepareQuery(ctx, cq)
+	bs, err := cq.buildSelect()
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+	query, args, err := bs.ToSql()
+	if err != nil {
+		return err
+	}
+	if log.IsDebug() {
+		log.DebugCtx(ctx, query, args)
+	}
+	query = m.rebind(query)
+	err = m.instrument(ctx, QuerySelect, cq.Table, query, args, func(ctx context.Context) (int64, error) {
+		rows, err := m.queryer(ctx).QueryxContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		var n int64
+		for rows.Next() {
+			if err = ctx.Err(); err != nil {
+				return n, err
+			}
+			if err = fn(rows); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, rows.Err()
+	})
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+	}
+	return err
+}
+
+// 导出列
+type ExportColumn struct {
+	// 查询列, 如 name 或 u.name AS user_name
+	Column string
+	// 表头
+	Title string
+	// 可选的值转换
+	Format func(v interface{}) interface{}
+}
+
+// 导出参数, Format 为 xlsx, csv 或 tsv
+type Export struct {
+	// 文件名, 不含扩展名, 可以包含中文
+	Filename string
+	Format   string
+	Sheet    string
+	Columns  []ExportColumn
+}
+
+// 将查询结果逐行写入响应, cq.Culumns 由 Columns 设置.
+// csv 与 tsv 逐行写出, 每 exportFlushRows 行刷新一次响应;
+// xlsx 需整体打包, 行数据先缓存在内存, 超过 16MB 后写入临时文件, 查询结束后才开始写出响应.
+// 查询开始前的错误正常返回; 开始写出后出错或请求取消时中断响应并记录日志
+func (h *HttpHandler) ExportQuery(c echo.Context, cq *CrudQuery, ex *Export) error {
+	format := strings.ToLower(ex.Format)
+	if format == "" {
+		format = excel.FormatXLSX
+	}
+	if format != excel.FormatXLSX && format != excel.FormatCSV && format != excel.FormatTSV {
+		return NewValidationError("不支持的导出格式 " + ex.Format)
+	}
+	if len(ex.Columns) == 0 {
+		return NewInternalError(errors.New("export columns required"))
+	}
+	cq.Culumns = make([]string, len(ex.Columns))
+	header := make([]interface{}, len(ex.Columns))
+	for i, col := range ex.Columns {
+		cq.Culumns[i] = col.Column
+		header[i] = col.Title
+	}
+
+	res := c.Response()
+	var writer excel.RowWriter
+	start := func() error {
+		if writer != nil {
+			return nil
+		}
+		res.Header().Set(echo.HeaderContentType, excel.ContentType(format))
+		res.Header().Set(echo.HeaderContentDisposition, excel.ContentDisposition(ex.Filename+"."+format))
+		if format != excel.FormatXLSX {
+			// xlsx 在 Close 时才写出内容, 之前出错仍可返回错误信息
+			res.WriteHeader(http.StatusOK)
+		}
+		var err error
+		if writer, err = excel.NewRowWriter(format, res, ex.Sheet); err != nil {
+			return err
+		}
+		return writer.WriteRow(header)
+	}
+
+	ctx := c.Request().Context()
+	var count int
+	err := h.GetAppContext().DBQueryEach(ctx, cq, func(rows *sqlx.Rows) error {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return err
+		}
+		for i, col := range ex.Columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			if col.Format != nil {
+				values[i] = col.Format(values[i])
+			}
+		}
+		if err = start(); err != nil {
+			return err
+		}
+		if err = writer.WriteRow(values); err != nil {
+			return err
+		}
+		if count++; count%exportFlushRows == 0 {
+			if err = writer.Flush(); err != nil {
+				return err
+			}
+			if f, ok := res.Writer.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return nil
+	}
+	if writer != nil {
+		excel.Discard(writer)
+	}
+	if !res.Committed {
+		res.Header().Del(echo.HeaderContentDisposition)
+		return err
+	}
+	// 响应已开始, 无法再返回错误信息
+	if ctx.Err() == nil {
+		log.ErrorfCtx(ctx, "export %s error %v", ex.Filename, err)
+	}
+	return nil
+}
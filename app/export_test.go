@@ -0,0 +1,79 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	excelize "github.com/360EntSecGroup-Skylar/excelize/v2"
+	"github.com/labstack/echo/v4"
+)
+
+func TestExportQuery(t *testing.T) {
+	m := newSqliteAppContext(t)
+	for _, name := range []string{"apple", "banana, ripe", "cherry"} {
+		if err := m.DBInsert("product", map[string]interface{}{"name": name, "tags": "fruit"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &HttpHandler{Ctx: &WebContext{AppCtx: m}}
+	export := func(format string, ctx context.Context) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.HTTPErrorHandler = HTTPErrorHandler(false)
+		req := httptest.NewRequest(http.MethodGet, "/export", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		cq := &CrudQuery{Table: "product", OrderBy: "id"}
+		err := h.ExportQuery(c, cq, &Export{
+			Filename: "产品列表",
+			Format:   format,
+			Columns: []ExportColumn{
+				{Column: "id", Title: "编号"},
+				{Column: "name", Title: "名称", Format: func(v interface{}) interface{} { return strings.ToUpper(v.(string)) }},
+			},
+		})
+		if err != nil {
+			e.HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	rec := export("csv", context.Background())
+	want := "\xEF\xBB\xBF编号,名称\n1,APPLE\n2,\"BANANA, RIPE\"\n3,CHERRY\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Fatalf("csv %d %q", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get(echo.HeaderContentDisposition); cd != `attachment; filename="____.csv"; filename*=UTF-8''%E4%BA%A7%E5%93%81%E5%88%97%E8%A1%A8.csv` {
+		t.Fatalf("content disposition %s", cd)
+	}
+
+	rec = export("tsv", context.Background())
+	if !strings.HasPrefix(rec.Body.String(), "编号\t名称\n1\tAPPLE\n") {
+		t.Fatalf("tsv %q", rec.Body.String())
+	}
+
+	rec = export("xlsx", context.Background())
+	f, err := excelize.OpenReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := f.GetRows("Sheet1")
+	if err != nil || len(rows) != 4 || rows[0][1] != "名称" || rows[3][1] != "CHERRY" {
+		t.Fatalf("xlsx rows %v %v", rows, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = export("csv", ctx)
+	if rec.Code == http.StatusOK || rec.Header().Get(echo.HeaderContentDisposition) != "" {
+		t.Fatalf("cancelled export %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = export("pdf", context.Background())
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported format %d", rec.Code)
+	}
+}
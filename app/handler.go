@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
+	excelize "github.com/360EntSecGroup-Skylar/excelize/v2"
 	"github.com/labstack/echo/v4"
 
+	"github.com/ca17/go-common/auth"
 	"github.com/ca17/go-common/common"
 	"github.com/ca17/go-common/conf"
+	"github.com/ca17/go-common/validutil"
 )
 
 type RestResult struct {
@@ -23,10 +25,12 @@ type RestResult struct {
 type WebContext struct {
 	AppCtx    *AppContext
 	AppConfig *conf.AppConfig
+	// 路由权限控制, 由 StartWebserver 设置
+	RBAC *RBAC
 }
 
 func NewWebContext(appCtx *AppContext, config *conf.AppConfig) *WebContext {
-	return &WebContext{AppCtx: appCtx, AppConfig: config}
+	return &WebContext{AppCtx: appCtx, AppConfig: config, RBAC: NewRBAC()}
 }
 
 type WebHandler interface {
@@ -45,6 +49,51 @@ func (h *HttpHandler) GetAppContext() *AppContext {
 	return h.Ctx.AppCtx
 }
 
+// 当前请求的令牌声明
+func (h *HttpHandler) GetClaims(c echo.Context) (*auth.Claims, error) {
+	claims, ok := auth.FromContext(c)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+	return claims, nil
+}
+
+// 将令牌中的用户 ID 设置为请求 context 的操作人, 用于填充审计列, 需在认证中间件之后使用.
+// 处理函数中使用 c.Request().Context() 调用 DB*Context 方法即可
+func ActorMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if claims, ok := auth.FromContext(c); ok && claims.UserID() != "" {
+				req := c.Request()
+				c.SetRequest(req.WithContext(WithActor(req.Context(), claims.UserID())))
+			}
+			return next(c)
+		}
+	}
+}
+
+// 当前请求的用户 ID, 未认证时返回空
+func (h *HttpHandler) GetUserID(c echo.Context) string {
+	if claims, ok := auth.FromContext(c); ok {
+		return claims.UserID()
+	}
+	return ""
+}
+
+func (h *HttpHandler) GetRoles(c echo.Context) []string {
+	if claims, ok := auth.FromContext(c); ok {
+		return claims.Roles
+	}
+	return nil
+}
+
+func (h *HttpHandler) GetTenant(c echo.Context) string {
+	if claims, ok := auth.FromContext(c); ok {
+		return claims.Tenant
+	}
+	return ""
+}
+
 func (h *HttpHandler) GetInternalError(err interface{}) *echo.HTTPError {
 	switch err.(type) {
 	case error:
@@ -62,7 +111,7 @@ func (h *HttpHandler) GoInternalErrPage(c echo.Context, err interface{}) error {
 	case string:
 		return c.Render(http.StatusInternalServerError, "err500", map[string]string{"message": err.(string)})
 	}
-	return c.Render(http.StatusInternalServerError, "err500", map[string]string{"message": err.(string)})
+	return c.Render(http.StatusInternalServerError, "err500", map[string]string{"message": fmt.Sprint(err)})
 }
 
 func (h *HttpHandler) RestResult(data interface{}) *RestResult {
@@ -82,17 +131,54 @@ func (h *HttpHandler) RestSucc(msg string) *RestResult {
 	}
 }
 
+// 通用错误结果, 需要区分错误类型时直接返回 AppError, 由 HTTPErrorHandler 统一处理
 func (h *HttpHandler) RestError(msg string) *RestResult {
 	return &RestResult{
-		Code:    9999,
+		Code:    CodeGeneric,
 		Msgtype: "error",
 		Msg:     msg,
 	}
 }
 
 func (h *HttpHandler) ParseFormInt64(c echo.Context, name string) (int64, error) {
-	return strconv.ParseInt(c.FormValue("id"), 10, 64)
+	return strconv.ParseInt(c.FormValue(name), 10, 64)
+
+}
+
+// 请求的语言偏好, 依次为 ContextLocale 与 Accept-Language
+func (h *HttpHandler) GetLocales(c echo.Context) []string {
+	var locales []string
+	if v, ok := c.Get(ContextLocale).(string); ok && v != "" {
+		locales = append(locales, v)
+	}
+	return append(locales, validutil.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))...)
+}
 
+var bindMessages = map[string][2]string{
+	validutil.LocaleZh: {"请求参数格式错误", "请求参数校验失败"},
+	validutil.LocaleEn: {"malformed request parameters", "request validation failed"},
+}
+
+// 绑定路径, 查询, JSON 或表单参数到 dto 并校验.
+// 校验失败返回 KindValidation 错误, Data 为字段名到本地化信息的映射, 语言由 GetLocales 确定
+func (h *HttpHandler) BindAndValidate(c echo.Context, dto interface{}) error {
+	trans := validutil.Translations.Translator(h.GetLocales(c)...)
+	msgs, ok := bindMessages[trans.Locale()]
+	if !ok {
+		msgs = bindMessages[validutil.LocaleZh]
+	}
+	if err := c.Bind(dto); err != nil {
+		return NewValidationError(msgs[0]).Wrap(err)
+	}
+	err := validutil.Validtool.Struct(dto)
+	if err == nil {
+		return nil
+	}
+	fields := validutil.TranslateErrors(err, trans)
+	if fields == nil {
+		return NewInternalError(err)
+	}
+	return NewValidationError(msgs[1]).WithData(fields)
 }
 
 func (h *HttpHandler) FetchExcelData(c echo.Context, sheet string) ([]map[string]string, error) {
@@ -112,7 +198,10 @@ func (h *HttpHandler) FetchExcelData(c echo.Context, sheet string) ([]map[string
 		return nil, errors.New("不是有效的 Excel 文件")
 	}
 	// 获取 Sheet1 上所有单元格
-	rows := f.GetRows(sheet)
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
 	head := make(map[int]string)
 	var data []map[string]string
 	for i, row := range rows {
@@ -133,6 +222,7 @@ func (h *HttpHandler) FetchExcelData(c echo.Context, sheet string) ([]map[string
 	return data, nil
 }
 
+// Deprecated: 使用 AppError, HTTPErrorHandler 仍按状态码处理该类型
 type HTTPError struct {
 	Code     int         `json:"-"`
 	Message  interface{} `json:"message"`
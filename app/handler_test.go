@@ -0,0 +1,104 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type bindUserForm struct {
+	ID     int64  `param:"id" json:"-" validate:"gt=0"`
+	Name   string `json:"name" validate:"required"`
+	Mobile string `json:"mobile" validate:"cnmobile"`
+	Site   string `json:"site" validate:"omitempty,cnurl"`
+	Page   int    `query:"page" json:"-" validate:"gte=1"`
+}
+
+func TestBindAndValidate(t *testing.T) {
+	h := &HttpHandler{}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(false)
+	var bound bindUserForm
+	e.POST("/users/:id", func(c echo.Context) error {
+		bound = bindUserForm{}
+		if err := h.BindAndValidate(c, &bound); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, h.RestResult(bound.Name))
+	})
+	post := func(body string) (*httptest.ResponseRecorder, RestResult) {
+		req := httptest.NewRequest(http.MethodPost, "/users/9?page=2", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var result RestResult
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec, result
+	}
+
+	rec, _ := post(`{"name":"tom","mobile":"13800138000","site":"example.com/a"}`)
+	if rec.Code != http.StatusOK || bound.ID != 9 || bound.Page != 2 {
+		t.Fatalf("bind %d %+v", rec.Code, bound)
+	}
+
+	rec, result := post(`{"mobile":"123"}`)
+	fields, _ := result.Data.(map[string]interface{})
+	if rec.Code != http.StatusBadRequest || result.Code != CodeValidation || len(fields) != 2 {
+		t.Fatalf("validate %d %+v", rec.Code, result)
+	}
+	if fields["name"] != "name为必填字段" || fields["mobile"] != "mobile必须是有效的手机号码" {
+		t.Fatalf("messages %v", fields)
+	}
+
+	// 路径参数使用 param 标签名, 而不是字段名
+	req := httptest.NewRequest(http.MethodPost, "/users/0?page=2", strings.NewReader(`{"name":"tom","mobile":"13800138000"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	fields, _ = result.Data.(map[string]interface{})
+	if rec.Code != http.StatusBadRequest || fields["id"] != "id必须大于0" {
+		t.Fatalf("param field name %d %+v", rec.Code, result)
+	}
+
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if lang := c.Request().Header.Get("X-Lang"); lang != "" {
+				c.Set(ContextLocale, lang)
+			}
+			return next(c)
+		}
+	})
+	for _, header := range []string{"Accept-Language", "X-Lang"} {
+		req := httptest.NewRequest(http.MethodPost, "/users/9?page=2", strings.NewReader(`{"mobile":"13800138000"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Accept-Language", "zh-CN")
+		req.Header.Set(header, "en-US")
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		fields, _ = result.Data.(map[string]interface{})
+		if result.Msg != "request validation failed" || fields["name"] != "name is a required field" {
+			t.Fatalf("%s: %+v", header, result)
+		}
+	}
+
+	rec, _ = post(`{"name":`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body %d", rec.Code)
+	}
+}
+
+func TestParseFormInt64(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?id=1&page=3", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	v, err := (&HttpHandler{}).ParseFormInt64(c, "page")
+	if err != nil || v != 3 {
+		t.Fatalf("page %d %v", v, err)
+	}
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+
+	DefaultHealthTimeout  = 3 * time.Second
+	DefaultHealthCacheTTL = 5 * time.Second
+)
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// 健康检查项
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+type HealthCheckFunc func(ctx context.Context) error
+
+func (f HealthCheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// 检查项选项, 零值使用注册表默认值
+type HealthCheckOptions struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+	// 是否参与存活检查, 默认只参与就绪检查
+	Liveness bool
+}
+
+// 单项检查结果
+type HealthCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// 健康检查报告
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+	opts    HealthCheckOptions
+	mu      sync.Mutex
+	last    *HealthCheckResult
+}
+
+// 健康检查注册表
+type HealthRegistry struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+
+	mu       sync.RWMutex
+	checks   []*healthCheck
+	stopping int32
+}
+
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{Timeout: DefaultHealthTimeout, CacheTTL: DefaultHealthCacheTTL}
+}
+
+// 注册检查项, 同名检查项会被替换
+func (r *HealthRegistry) Register(name string, checker HealthChecker, opts ...HealthCheckOptions) {
+	hc := &healthCheck{name: name, checker: checker}
+	if len(opts) > 0 {
+		hc.opts = opts[0]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.checks {
+		if c.name == name {
+			r.checks[i] = hc
+			return
+		}
+	}
+	r.checks = append(r.checks, hc)
+}
+
+// 标记服务正在关闭, 就绪检查返回失败, 便于负载均衡摘除流量
+func (r *HealthRegistry) SetShuttingDown() {
+	atomic.StoreInt32(&r.stopping, 1)
+}
+
+// 执行存活检查
+func (r *HealthRegistry) Liveness(ctx context.Context) *HealthReport {
+	return r.run(ctx, true)
+}
+
+// 执行就绪检查
+func (r *HealthRegistry) Readiness(ctx context.Context) *HealthReport {
+	report := r.run(ctx, false)
+	if atomic.LoadInt32(&r.stopping) == 1 {
+		report.Status = HealthDown
+		report.Checks = append(report.Checks, HealthCheckResult{
+			Name:      "server",
+			Status:    HealthDown,
+			Error:     "shutting down",
+			CheckedAt: time.Now(),
+		})
+	}
+	return report
+}
+
+func (r *HealthRegistry) run(ctx context.Context, liveness bool) *HealthReport {
+	r.mu.RLock()
+	var checks []*healthCheck
+	for _, c := range r.checks {
+		if !liveness || c.opts.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := &HealthReport{Status: HealthUp, Checks: make([]HealthCheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			report.Checks[i] = r.check(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, c := range report.Checks {
+		if c.Status != HealthUp {
+			report.Status = HealthDown
+		}
+	}
+	return report
+}
+
+// 执行单项检查, 缓存有效期内直接返回上次结果
+func (r *HealthRegistry) check(ctx context.Context, c *healthCheck) HealthCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl := c.opts.CacheTTL
+	if ttl == 0 {
+		ttl = r.CacheTTL
+	}
+	if c.last != nil && time.Since(c.last.CheckedAt) < ttl {
+		return *c.last
+	}
+	timeout := c.opts.Timeout
+	if timeout == 0 {
+		timeout = r.Timeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := runHealthCheck(cctx, c.checker)
+	result := &HealthCheckResult{
+		Name:      c.name,
+		Status:    HealthUp,
+		Latency:   float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+	c.last = result
+	return *result
+}
+
+// 检查项不响应 ctx 时按超时返回
+func runHealthCheck(ctx context.Context, checker HealthChecker) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("health check panic: %v", r)
+			}
+		}()
+		done <- checker.Check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 存活检查接口
+func (r *HealthRegistry) LivenessHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return writeHealthReport(c, r.Liveness(c.Request().Context()))
+	}
+}
+
+// 就绪检查接口
+func (r *HealthRegistry) ReadinessHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return writeHealthReport(c, r.Readiness(c.Request().Context()))
+	}
+}
+
+func writeHealthReport(c echo.Context, report *HealthReport) error {
+	status := http.StatusOK
+	if report.Status != HealthUp {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
+
+// 数据库连接检查
+func DBHealthChecker(db *sqlx.DB) HealthChecker {
+	return HealthCheckFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// MongoDB 连接检查
+func MongoHealthChecker(client *mongo.Client) HealthChecker {
+	return HealthCheckFunc(func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	})
+}
+
+// gRPC 连接状态检查, 空闲连接在下次调用时自动建立, 视为正常
+func GrpcHealthChecker(conn *grpc.ClientConn) HealthChecker {
+	return HealthCheckFunc(func(ctx context.Context) error {
+		switch state := conn.GetState(); state {
+		case connectivity.Ready, connectivity.Idle:
+			return nil
+		default:
+			return fmt.Errorf("grpc connection %s", state)
+		}
+	})
+}
+
+// 注册 ContextManager 中已初始化资源的检查项
+func (r *HealthRegistry) RegisterResources(appctx *AppContext) {
+	if appctx == nil || appctx.Context == nil {
+		return
+	}
+	cm := appctx.Context
+	if db := cm.DBPool(); db != nil {
+		r.Register("database", DBHealthChecker(db))
+	}
+	if client := cm.MongoDb(); client != nil {
+		r.Register("mongodb", MongoHealthChecker(client))
+	}
+	if conn := cm.GrpConn(); conn != nil {
+		r.Register("grpc", GrpcHealthChecker(conn))
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	m := newSqliteAppContext(t)
+	r := NewHealthRegistry()
+	r.RegisterResources(m)
+	calls := 0
+	r.Register("cached", HealthCheckFunc(func(ctx context.Context) error {
+		calls++
+		return nil
+	}), HealthCheckOptions{Liveness: true})
+	r.Register("slow", HealthCheckFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), HealthCheckOptions{Timeout: 20 * time.Millisecond})
+
+	e := echo.New()
+	e.GET(HealthzPath, r.LivenessHandler())
+	e.GET(ReadyzPath, r.ReadinessHandler())
+
+	get := func(path string) (int, HealthReport) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, report
+	}
+
+	code, report := get(ReadyzPath)
+	if code != http.StatusServiceUnavailable || len(report.Checks) != 3 {
+		t.Fatalf("unexpected readiness %d %+v", code, report)
+	}
+	if report.Checks[0].Name != "database" || report.Checks[0].Status != HealthUp {
+		t.Fatalf("unexpected database check %+v", report.Checks[0])
+	}
+	if report.Checks[2].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected timeout, got %+v", report.Checks[2])
+	}
+
+	code, report = get(HealthzPath)
+	if code != http.StatusOK || len(report.Checks) != 1 || calls != 1 {
+		t.Fatalf("unexpected liveness %d %+v calls %d", code, report, calls)
+	}
+
+	r.Register("slow", HealthCheckFunc(func(ctx context.Context) error { return errors.New("boom") }))
+	r.Register("slow", HealthCheckFunc(func(ctx context.Context) error { return nil }))
+	if code, _ = get(ReadyzPath); code != http.StatusOK {
+		t.Fatalf("expected ready, got %d", code)
+	}
+	r.SetShuttingDown()
+	if code, _ = get(ReadyzPath); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready while shutting down, got %d", code)
+	}
+}
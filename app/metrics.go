@@ -0,0 +1,268 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 默认请求耗时分桶, 单位秒
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 请求指标键, 按方法, 路由模板与状态码区分
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+type latencyHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Web 服务指标, 以 Prometheus 文本格式输出
+type Metrics struct {
+	// 指标名前缀, 默认为空
+	Namespace string
+	Buckets   []float64
+
+	appctx   *AppContext
+	inflight int64
+	mu       sync.Mutex
+	requests map[requestKey]*latencyHistogram
+	started  time.Time
+}
+
+func NewMetrics(appctx *AppContext) *Metrics {
+	return &Metrics{
+		Buckets:  DefaultLatencyBuckets,
+		appctx:   appctx,
+		requests: make(map[requestKey]*latencyHistogram),
+		started:  time.Now(),
+	}
+}
+
+// 请求指标中间件, 未匹配路由的请求记录为 unmatched, 避免标签无限增长.
+// 已匹配路由的处理函数返回 404 时仍按路由模板记录
+func (mt *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			atomic.AddInt64(&mt.inflight, 1)
+			defer atomic.AddInt64(&mt.inflight, -1)
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil {
+				if !c.Response().Committed {
+					status = AsAppError(err).Status()
+				}
+			}
+			route := c.Path()
+			if route == "" || isNotFoundHandler(c.Handler()) {
+				route = "unmatched"
+			}
+			mt.Observe(c.Request().Method, route, status, time.Since(start))
+			return err
+		}
+	}
+}
+
+// 未匹配路由时 echo 使用 NotFoundHandler, 此时 c.Path() 为原始请求路径
+func isNotFoundHandler(h echo.HandlerFunc) bool {
+	return h != nil && reflect.ValueOf(h).Pointer() == reflect.ValueOf(echo.NotFoundHandler).Pointer()
+}
+
+// 记录一次请求
+func (mt *Metrics) Observe(method string, route string, status int, d time.Duration) {
+	key := requestKey{method: method, route: route, status: status}
+	seconds := d.Seconds()
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	h, ok := mt.requests[key]
+	if !ok {
+		h = &latencyHistogram{counts: make([]uint64, len(mt.Buckets))}
+		mt.requests[key] = h
+	}
+	for i, b := range mt.Buckets {
+		if seconds <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// 指标输出接口
+func (mt *Metrics) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var buf bytes.Buffer
+		mt.Write(&buf)
+		return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+	}
+}
+
+// 以 Prometheus 文本格式写出全部指标
+func (mt *Metrics) Write(w io.Writer) {
+	mt.writeRequests(w)
+	mt.writeDBStats(w)
+	mt.writeTableStats(w)
+	mt.writeRuntime(w)
+}
+
+func (mt *Metrics) name(name string) string {
+	if mt.Namespace == "" {
+		return name
+	}
+	return mt.Namespace + "_" + name
+}
+
+func (mt *Metrics) writeHeader(w io.Writer, name string, typ string, help string) string {
+	name = mt.name(name)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return name
+}
+
+func (mt *Metrics) writeGauge(w io.Writer, name string, help string, value interface{}) {
+	name = mt.writeHeader(w, name, "gauge", help)
+	fmt.Fprintf(w, "%s %v\n", name, value)
+}
+
+func (mt *Metrics) writeRequests(w io.Writer) {
+	mt.mu.Lock()
+	keys := make([]requestKey, 0, len(mt.requests))
+	hists := make(map[requestKey]latencyHistogram, len(mt.requests))
+	for k, h := range mt.requests {
+		keys = append(keys, k)
+		hists[k] = latencyHistogram{counts: append([]uint64(nil), h.counts...), count: h.count, sum: h.sum}
+	}
+	mt.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	name := mt.writeHeader(w, "http_requests_total", "counter", "Total number of HTTP requests.")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, k.labels(), hists[k].count)
+	}
+	name = mt.writeHeader(w, "http_request_duration_seconds", "histogram", "HTTP request latency in seconds.")
+	for _, k := range keys {
+		h := hists[k]
+		labels := k.labels()
+		for i, b := range mt.Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(b), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+	mt.writeGauge(w, "http_requests_in_flight", "Number of HTTP requests being served.", atomic.LoadInt64(&mt.inflight))
+}
+
+func (mt *Metrics) writeDBStats(w io.Writer) {
+	if mt.appctx == nil || mt.appctx.Context == nil {
+		return
+	}
+	pool := mt.appctx.Context.DBPool()
+	if pool == nil {
+		return
+	}
+	stats := pool.Stats()
+	mt.writeGauge(w, "db_max_open_connections", "Maximum number of open connections to the database.", stats.MaxOpenConnections)
+	mt.writeGauge(w, "db_open_connections", "Number of established connections.", stats.OpenConnections)
+	mt.writeGauge(w, "db_in_use_connections", "Number of connections currently in use.", stats.InUse)
+	mt.writeGauge(w, "db_idle_connections", "Number of idle connections.", stats.Idle)
+	name := mt.writeHeader(w, "db_wait_count_total", "counter", "Total number of connections waited for.")
+	fmt.Fprintf(w, "%s %d\n", name, stats.WaitCount)
+	name = mt.writeHeader(w, "db_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.")
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(stats.WaitDuration.Seconds()))
+	name = mt.writeHeader(w, "db_max_idle_closed_total", "counter", "Total number of connections closed due to SetMaxIdleConns.")
+	fmt.Fprintf(w, "%s %d\n", name, stats.MaxIdleClosed)
+	name = mt.writeHeader(w, "db_max_lifetime_closed_total", "counter", "Total number of connections closed due to SetConnMaxLifetime.")
+	fmt.Fprintf(w, "%s %d\n", name, stats.MaxLifetimeClosed)
+}
+
+// 已注册 TableMetrics 查询钩子时输出按表统计
+func (mt *Metrics) writeTableStats(w io.Writer) {
+	if mt.appctx == nil {
+		return
+	}
+	var tm *TableMetrics
+	mt.appctx.hooksMu.RLock()
+	for _, h := range mt.appctx.hooks {
+		if v, ok := h.(*TableMetrics); ok {
+			tm = v
+			break
+		}
+	}
+	mt.appctx.hooksMu.RUnlock()
+	if tm == nil {
+		return
+	}
+	stats := tm.Snapshot()
+	name := mt.writeHeader(w, "db_queries_total", "counter", "Total number of SQL queries by table.")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s{table=\"%s\"} %d\n", name, escapeLabel(s.Table), s.Count)
+	}
+	name = mt.writeHeader(w, "db_query_errors_total", "counter", "Total number of failed SQL queries by table.")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s{table=\"%s\"} %d\n", name, escapeLabel(s.Table), s.Errors)
+	}
+	name = mt.writeHeader(w, "db_query_duration_seconds_total", "counter", "Total time spent in SQL queries by table.")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s{table=\"%s\"} %s\n", name, escapeLabel(s.Table), formatFloat(s.TotalDuration.Seconds()))
+	}
+}
+
+func (mt *Metrics) writeRuntime(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	mt.writeGauge(w, "go_goroutines", "Number of goroutines that currently exist.", runtime.NumGoroutine())
+	mt.writeGauge(w, "go_threads", "Number of OS threads created.", threadCount())
+	mt.writeGauge(w, "go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", ms.Alloc)
+	mt.writeGauge(w, "go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", ms.HeapInuse)
+	mt.writeGauge(w, "go_memstats_heap_objects", "Number of allocated objects.", ms.HeapObjects)
+	mt.writeGauge(w, "go_memstats_sys_bytes", "Number of bytes obtained from system.", ms.Sys)
+	name := mt.writeHeader(w, "go_gc_cycles_total", "counter", "Number of completed GC cycles.")
+	fmt.Fprintf(w, "%s %d\n", name, ms.NumGC)
+	name = mt.writeHeader(w, "go_gc_pause_seconds_total", "counter", "Total GC pause time in seconds.")
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(time.Duration(ms.PauseTotalNs).Seconds()))
+	mt.writeGauge(w, "process_uptime_seconds", "Seconds since the metrics were created.", formatFloat(time.Since(mt.started).Seconds()))
+}
+
+func threadCount() int {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return n
+}
+
+func (k requestKey) labels() string {
+	return fmt.Sprintf("method=\"%s\",route=\"%s\",status=\"%d\"", escapeLabel(k.method), escapeLabel(k.route), k.status)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
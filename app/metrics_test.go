@@ -0,0 +1,54 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newSqliteAppContext(t)
+	m.AddQueryHook(NewTableMetrics())
+	metrics := NewMetrics(m)
+	e := echo.New()
+	e.Use(metrics.Middleware())
+	e.GET("/product/:id", func(c echo.Context) error {
+		var name string
+		_ = m.Context.DBPool().Get(&name, "SELECT name FROM product WHERE id = ?", c.Param("id"))
+		if name == "" {
+			return NewNotFoundError("product not found")
+		}
+		return c.String(http.StatusOK, name)
+	})
+	e.GET("/metrics", metrics.Handler())
+
+	m.Context.DBPool().MustExec("INSERT INTO product (id, name) VALUES (1, 'a'), (2, 'b')")
+	for _, uri := range []string{"/product/1", "/product/2", "/product/3", "/missing", "/missing/again"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, uri, nil))
+	}
+	m.DBGet(&CrudGet{Table: "product", Culumns: []string{"name"}, Filter: map[string]interface{}{"id": 1}, ResultRef: new(string)})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, expect := range []string{
+		`http_requests_total{method="GET",route="/product/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/product/:id",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 2`,
+		`http_request_duration_seconds_bucket{method="GET",route="/product/:id",status="200",le="+Inf"} 2`,
+		"http_requests_in_flight 1",
+		"db_max_open_connections 1",
+		`db_queries_total{table="product"} 1`,
+		"# TYPE go_goroutines gauge",
+	} {
+		if !strings.Contains(body, expect) {
+			t.Fatalf("missing %q in\n%s", expect, body)
+		}
+	}
+	if strings.Contains(body, `route="/missing`) {
+		t.Fatalf("unmatched path used as label\n%s", body)
+	}
+}
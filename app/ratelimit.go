@@ -0,0 +1,302 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/ca17/go-common/auth"
+	"github.com/ca17/go-common/conf"
+	"github.com/ca17/go-common/log"
+)
+
+// 限流算法
+type RateAlgorithm int
+
+const (
+	// 令牌桶, 允许 Burst 大小的突发请求
+	TokenBucket RateAlgorithm = iota
+	// 滑动窗口计数, 按前一窗口计数加权估算
+	SlidingWindow
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// 限流参数, Window 内允许 Limit 个请求
+type RateLimit struct {
+	Algorithm RateAlgorithm
+	Limit     int
+	Window    time.Duration
+	// 令牌桶容量, 默认等于 Limit
+	Burst int
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Limit
+}
+
+// 令牌桶每毫秒补充的令牌数
+func (l RateLimit) ratePerMs() float64 {
+	return float64(l.Limit) / float64(l.Window.Milliseconds())
+}
+
+// 限流结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 距离配额完全恢复的时间
+	Reset time.Duration
+	// 被拒绝时建议的重试等待时间
+	RetryAfter time.Duration
+}
+
+// 限流计数存储
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// 令牌桶结果, tokens 为扣减后的剩余令牌
+func tokenBucketResult(limit RateLimit, allowed bool, tokens float64) *RateLimitResult {
+	rate := limit.ratePerMs()
+	r := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.burst(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.burst())-tokens)/rate) * time.Millisecond,
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration(math.Ceil((1-tokens)/rate)) * time.Millisecond
+	}
+	return r
+}
+
+// 滑动窗口结果, count 为当前估算的请求数, elapsed 为当前窗口已过去的时间
+func slidingWindowResult(limit RateLimit, allowed bool, count float64, elapsed time.Duration) *RateLimitResult {
+	r := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Limit,
+		Remaining: limit.Limit - int(math.Ceil(count)),
+		Reset:     limit.Window - elapsed,
+	}
+	if r.Remaining < 0 {
+		r.Remaining = 0
+	}
+	if !allowed {
+		r.RetryAfter = r.Reset
+	}
+	return r
+}
+
+type rateEntry struct {
+	// 令牌桶
+	tokens float64
+	last   time.Time
+	// 滑动窗口
+	start     time.Time
+	count     int
+	prevCount int
+	expire    time.Time
+}
+
+// 内存计数存储, 适用于单实例部署
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateEntry
+	purgeAt time.Time
+	now     func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[string]*rateEntry), now: time.Now}
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/%s", limit.Limit, limit.Window)
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateEntry{tokens: float64(limit.burst()), last: now, start: now.Truncate(limit.Window)}
+		s.entries[key] = e
+	}
+	e.expire = now.Add(2 * limit.Window)
+
+	if limit.Algorithm == SlidingWindow {
+		start := now.Truncate(limit.Window)
+		if !start.Equal(e.start) {
+			if start.Sub(e.start) == limit.Window {
+				e.prevCount = e.count
+			} else {
+				e.prevCount = 0
+			}
+			e.count = 0
+			e.start = start
+		}
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(limit.Window)
+		count := float64(e.prevCount)*weight + float64(e.count)
+		if count+1 > float64(limit.Limit) {
+			return slidingWindowResult(limit, false, count, elapsed), nil
+		}
+		e.count++
+		return slidingWindowResult(limit, true, count+1, elapsed), nil
+	}
+
+	elapsed := float64(now.Sub(e.last).Milliseconds())
+	e.tokens = math.Min(float64(limit.burst()), e.tokens+elapsed*limit.ratePerMs())
+	e.last = now
+	if e.tokens < 1 {
+		return tokenBucketResult(limit, false, e.tokens), nil
+	}
+	e.tokens--
+	return tokenBucketResult(limit, true, e.tokens), nil
+}
+
+// 每分钟清理一次过期计数
+func (s *MemoryRateLimitStore) purge(now time.Time) {
+	if now.Before(s.purgeAt) {
+		return
+	}
+	s.purgeAt = now.Add(time.Minute)
+	for k, e := range s.entries {
+		if e.expire.Before(now) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// 限流维度
+type RateKeyFunc func(c echo.Context) string
+
+// 按客户端 IP 限流
+func RateKeyIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// 按令牌中的用户 ID 限流, 未认证时按 IP
+func RateKeySubject(c echo.Context) string {
+	if claims, ok := auth.FromContext(c); ok && claims.UserID() != "" {
+		return "sub:" + claims.UserID()
+	}
+	return RateKeyIP(c)
+}
+
+type RateLimiterConfig struct {
+	Skipper middleware.Skipper
+	Limit   RateLimit
+	// 计数存储, 默认为内存存储
+	Store RateLimitStore
+	// 默认 RateKeyIP
+	KeyFunc RateKeyFunc
+	// 计数键前缀, 用于区分不同路由组的限额
+	Name string
+}
+
+// 限流中间件, 可用于 echo.Group 实现按路由组限流.
+// 存储异常时放行请求并记录日志
+func RateLimitMiddleware(config RateLimiterConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateKeyIP
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			key := config.Name + ":" + config.KeyFunc(c)
+			result, err := config.Store.Allow(c.Request().Context(), key, config.Limit)
+			if err != nil {
+				log.ErrorfCtx(c.Request().Context(), "rate limit %s error %+v", key, err)
+				return next(c)
+			}
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderRateLimitReset, strconv.FormatInt(ceilSeconds(result.Reset), 10))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+				return c.JSON(http.StatusTooManyRequests, &RestResult{
+					Code:    http.StatusTooManyRequests,
+					Msgtype: "error",
+					Msg:     "请求过于频繁, 请稍后再试",
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// 根据 WebConfig.RateLimits 生成限流中间件, 请求按规则顺序逐条检查
+func rateLimitFromConfig(webcfg *conf.WebConfig, store RateLimitStore) (echo.MiddlewareFunc, error) {
+	var limiters []echo.MiddlewareFunc
+	for i, rc := range webcfg.RateLimits {
+		rule := SkipRule{Method: strings.ToUpper(rc.Method), Path: rc.Path}
+		if err := checkSkipPath(rc.Path); err != nil {
+			return nil, err
+		}
+		if rc.Limit <= 0 || rc.Window <= 0 {
+			return nil, fmt.Errorf("invalid rate limit for %s", rc.Path)
+		}
+		limit := RateLimit{Limit: rc.Limit, Window: time.Duration(rc.Window) * time.Second, Burst: rc.Burst}
+		switch rc.Algorithm {
+		case "", "token_bucket":
+		case "sliding_window":
+			limit.Algorithm = SlidingWindow
+		default:
+			return nil, fmt.Errorf("unsupported rate limit algorithm %s", rc.Algorithm)
+		}
+		keyFunc := RateKeyIP
+		switch rc.Key {
+		case "", "ip":
+		case "subject":
+			keyFunc = RateKeySubject
+		default:
+			return nil, fmt.Errorf("unsupported rate limit key %s", rc.Key)
+		}
+		limiters = append(limiters, RateLimitMiddleware(RateLimiterConfig{
+			Skipper: func(c echo.Context) bool {
+				return !rule.Match(c.Request().Method, c.Request().URL.Path)
+			},
+			Limit:   limit,
+			Store:   store,
+			KeyFunc: keyFunc,
+			Name:    fmt.Sprintf("rl:%d", i),
+		}))
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		for i := len(limiters) - 1; i >= 0; i-- {
+			next = limiters[i](next)
+		}
+		return next
+	}, nil
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/ca17/go-common/conf"
+)
+
+// 执行 Lua 脚本的 Redis 客户端, 可适配任意 Redis 客户端库
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// 令牌桶脚本, 返回 {是否允许, 剩余令牌}
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 't', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 't', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// 滑动窗口脚本, KEYS 为当前与前一窗口计数, 返回 {是否允许, 估算请求数}
+const slidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local weight = tonumber(ARGV[3])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local estimated = prev * weight + count
+if estimated + 1 > limit then
+	return {0, tostring(estimated)}
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, tostring(estimated + 1)}
+`
+
+// Redis 计数存储, 多实例共享限额
+type RedisRateLimitStore struct {
+	client RedisEvaler
+	prefix string
+	now    func() time.Time
+}
+
+func NewRedisRateLimitStore(client RedisEvaler, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix, now: time.Now}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/%s", limit.Limit, limit.Window)
+	}
+	now := s.now()
+	key = s.prefix + key
+	if limit.Algorithm == SlidingWindow {
+		window := limit.Window.Milliseconds()
+		ms := now.UnixNano() / int64(time.Millisecond)
+		index := ms / window
+		elapsed := time.Duration(ms-index*window) * time.Millisecond
+		weight := 1 - float64(elapsed)/float64(limit.Window)
+		// 使用 hash tag 保证两个窗口的 key 在 redis cluster 中落在同一个 slot
+		tag := "{" + key + "}:"
+		reply, err := s.client.Eval(ctx, slidingWindowScript,
+			[]string{tag + strconv.FormatInt(index, 10), tag + strconv.FormatInt(index-1, 10)},
+			limit.Limit, window, strconv.FormatFloat(weight, 'f', 6, 64))
+		if err != nil {
+			return nil, err
+		}
+		allowed, count, err := parseRateReply(reply)
+		if err != nil {
+			return nil, err
+		}
+		return slidingWindowResult(limit, allowed, count, elapsed), nil
+	}
+
+	reply, err := s.client.Eval(ctx, tokenBucketScript, []string{key},
+		strconv.FormatFloat(limit.ratePerMs(), 'f', -1, 64), limit.burst(), now.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	allowed, tokens, err := parseRateReply(reply)
+	if err != nil {
+		return nil, err
+	}
+	return tokenBucketResult(limit, allowed, tokens), nil
+}
+
+func parseRateReply(reply interface{}) (bool, float64, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	s, _ := values[1].(string)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	return allowed == 1, f, nil
+}
+
+// 基于 go-redis 的脚本执行, 先尝试 EVALSHA, 脚本未缓存时回退为 EVAL
+type redisScripter struct {
+	client  redis.Scripter
+	scripts sync.Map
+}
+
+// 使用 go-redis 客户端执行脚本, 可传入 *redis.Client, *redis.ClusterClient 等
+func NewRedisEvaler(client redis.Scripter) RedisEvaler {
+	return &redisScripter{client: client}
+}
+
+// 使用 RedisConfig 创建带连接池的客户端, Host 格式为 host:port, 未指定端口时使用 6379
+func NewRedisClient(config *conf.RedisConfig) RedisEvaler {
+	addr := config.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "6379")
+	}
+	return NewRedisEvaler(redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: config.Password,
+		DB:       config.DB,
+	}))
+}
+
+func (r *redisScripter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	v, ok := r.scripts.Load(script)
+	if !ok {
+		v, _ = r.scripts.LoadOrStore(script, redis.NewScript(script))
+	}
+	return v.(*redis.Script).Run(ctx, r.client, keys, args...).Result()
+}
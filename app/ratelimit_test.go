@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/ca17/go-common/conf"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+
+	bucket := RateLimit{Limit: 2, Window: time.Second, Burst: 3}
+	for i := 0; i < 3; i++ {
+		if r, _ := s.Allow(ctx, "a", bucket); !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d unexpected %+v", i, r)
+		}
+	}
+	r, _ := s.Allow(ctx, "a", bucket)
+	if r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected denied, got %+v", r)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if r, _ = s.Allow(ctx, "a", bucket); !r.Allowed {
+		t.Fatalf("expected refill, got %+v", r)
+	}
+
+	window := RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+	for i := 0; i < 4; i++ {
+		if r, _ = s.Allow(ctx, "b", window); !r.Allowed {
+			t.Fatalf("request %d denied", i)
+		}
+	}
+	if r, _ = s.Allow(ctx, "b", window); r.Allowed || r.Remaining != 0 {
+		t.Fatalf("expected denied, got %+v", r)
+	}
+	// 下一窗口过半, 前一窗口计数按一半计算
+	now = now.Truncate(time.Minute).Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if r, _ = s.Allow(ctx, "b", window); !r.Allowed {
+			t.Fatalf("request %d denied in next window", i)
+		}
+	}
+	if r, _ = s.Allow(ctx, "b", window); r.Allowed {
+		t.Fatalf("expected weighted window denied, got %+v", r)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	config := &testAppConfig{web: conf.WebConfig{RateLimits: []conf.RateLimitConfig{
+		{Method: "post", Path: "/login", Limit: 2, Window: 60, Algorithm: "sliding_window"},
+	}}}
+	s := NewServer(config, nil, nil, publicHandler{})
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		rec = httptest.NewRecorder()
+		s.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+	}
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" ||
+		rec.Header().Get(HeaderRateLimitLimit) != "2" || rec.Header().Get(HeaderRateLimitRemaining) != "0" {
+		t.Fatalf("expected 429, got %d %v", rec.Code, rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	s.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get(HeaderRateLimitLimit) != "" {
+		t.Fatalf("unexpected limit on other route %d", rec.Code)
+	}
+}
+
+func TestServerRateKeyIgnoresForgedHeaders(t *testing.T) {
+	keyOf := func(s *Server, remote string, xff string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+			req.Header.Set("X-Real-IP", xff)
+		}
+		return RateKeyIP(s.Echo.NewContext(req, httptest.NewRecorder()))
+	}
+
+	s := NewServer(&testAppConfig{}, nil, nil)
+	if key := keyOf(s, "203.0.113.7:4000", "198.51.100.1"); key != "ip:203.0.113.7" {
+		t.Fatalf("forged header changed key: %s", key)
+	}
+
+	s = NewServer(&testAppConfig{web: conf.WebConfig{TrustedProxies: "10.0.0.0/8, 192.0.2.1"}}, nil, nil)
+	if key := keyOf(s, "10.0.0.2:4000", "198.51.100.1"); key != "ip:198.51.100.1" {
+		t.Fatalf("trusted proxy key: %s", key)
+	}
+	// 客户端伪造的前置地址不被采用
+	if key := keyOf(s, "192.0.2.1:4000", "1.1.1.1, 198.51.100.1"); key != "ip:198.51.100.1" {
+		t.Fatalf("forged xff behind proxy key: %s", key)
+	}
+	if key := keyOf(s, "203.0.113.7:4000", "198.51.100.1"); key != "ip:203.0.113.7" {
+		t.Fatalf("untrusted peer key: %s", key)
+	}
+
+	s = NewServer(&testAppConfig{web: conf.WebConfig{TrustedProxies: "not-an-ip"}}, nil, nil)
+	if s.initErr == nil {
+		t.Fatal("expected invalid trusted proxy error")
+	}
+}
+
+func TestServerRateLimitRedisWithoutConfig(t *testing.T) {
+	config := &testAppConfig{web: conf.WebConfig{RateLimitStore: "redis", RateLimits: []conf.RateLimitConfig{
+		{Path: "/login", Limit: 2, Window: 60},
+	}}}
+	s := NewServer(config, nil, nil)
+	if s.initErr == nil {
+		t.Fatal("expected missing redis config error")
+	}
+}
+
+// 使用 miniredis 执行实际的 Lua 脚本
+func TestRedisRateLimitStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("pass")
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewRedisRateLimitStore(NewRedisClient(&conf.RedisConfig{Host: mr.Addr(), Password: "pass", DB: 1}), "test:")
+	s.now = func() time.Time { return now }
+
+	bucket := RateLimit{Limit: 2, Window: time.Second, Burst: 3}
+	for i := 0; i < 3; i++ {
+		r, err := s.Allow(ctx, "a", bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d unexpected %+v", i, r)
+		}
+	}
+	r, _ := s.Allow(ctx, "a", bucket)
+	if r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected denied, got %+v", r)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if r, _ = s.Allow(ctx, "a", bucket); !r.Allowed {
+		t.Fatalf("expected refill, got %+v", r)
+	}
+
+	window := RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+	for i := 0; i < 4; i++ {
+		if r, _ = s.Allow(ctx, "b", window); !r.Allowed {
+			t.Fatalf("request %d denied", i)
+		}
+	}
+	if r, _ = s.Allow(ctx, "b", window); r.Allowed || r.Remaining != 0 {
+		t.Fatalf("expected denied, got %+v", r)
+	}
+	now = now.Truncate(time.Minute).Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if r, _ = s.Allow(ctx, "b", window); !r.Allowed {
+			t.Fatalf("request %d denied in next window", i)
+		}
+	}
+	if r, _ = s.Allow(ctx, "b", window); r.Allowed {
+		t.Fatalf("expected weighted window denied, got %+v", r)
+	}
+	mr.Select(1)
+	if !mr.Exists("test:a") {
+		t.Fatalf("expected key in db 1, got %v", mr.Keys())
+	}
+	index := now.UnixNano() / int64(time.Minute)
+	if !mr.Exists("{test:b}:" + strconv.FormatInt(index, 10)) {
+		t.Fatalf("expected hash tagged window key, got %v", mr.Keys())
+	}
+}
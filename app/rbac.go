@@ -0,0 +1,273 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"gopkg.in/yaml.v2"
+
+	"github.com/ca17/go-common/auth"
+)
+
+// 查看路由权限列表所需权限
+const RBACRoutesPermission = "rbac:routes"
+
+// 路由权限列表接口路径
+const RBACRoutesPath = "/rbac/routes"
+
+// 角色, 权限格式为 resource:action, 任一段可使用通配符 *, 末段 * 匹配剩余所有段
+type Role struct {
+	Name        string   `yaml:"name" json:"name"`
+	Inherits    []string `yaml:"inherits" json:"inherits"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+}
+
+// 路由权限
+type RoutePermission struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// 基于角色的访问控制
+type RBAC struct {
+	// 返回 true 时跳过权限检查
+	Skipper middleware.Skipper
+
+	mu     sync.RWMutex
+	roles  map[string]*Role
+	perms  map[string][]string
+	routes map[string]*RoutePermission
+}
+
+func NewRBAC() *RBAC {
+	return &RBAC{
+		Skipper: middleware.DefaultSkipper,
+		roles:   make(map[string]*Role),
+		perms:   make(map[string][]string),
+		routes:  make(map[string]*RoutePermission),
+	}
+}
+
+// 设置全部角色, 继承关系存在循环或引用不存在的角色时返回错误
+func (r *RBAC) SetRoles(roles []Role) error {
+	roleMap := make(map[string]*Role, len(roles))
+	for i := range roles {
+		role := roles[i]
+		if role.Name == "" {
+			return fmt.Errorf("role name is empty")
+		}
+		roleMap[role.Name] = &role
+	}
+	perms := make(map[string][]string, len(roleMap))
+	for name := range roleMap {
+		result, err := resolvePermissions(roleMap, name, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		perms[name] = result
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles = roleMap
+	r.perms = perms
+	return nil
+}
+
+// 展开角色继承的全部权限
+func resolvePermissions(roles map[string]*Role, name string, visiting map[string]bool) ([]string, error) {
+	role, ok := roles[name]
+	if !ok {
+		return nil, fmt.Errorf("role %s not found", name)
+	}
+	if visiting[name] {
+		return nil, fmt.Errorf("role %s inherits itself", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	result := append([]string(nil), role.Permissions...)
+	for _, parent := range role.Inherits {
+		perms, err := resolvePermissions(roles, parent, visiting)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, perms...)
+	}
+	return result, nil
+}
+
+// 从 YAML 加载角色, 格式为 roles: [{name, inherits, permissions}]
+func (r *RBAC) LoadYAML(data []byte) error {
+	var config struct {
+		Roles []Role `yaml:"roles"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	return r.SetRoles(config.Roles)
+}
+
+func (r *RBAC) LoadYAMLFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return r.LoadYAML(data)
+}
+
+type rbacRoleRow struct {
+	Name        string `db:"name"`
+	Inherits    string `db:"inherits"`
+	Permissions string `db:"permissions"`
+}
+
+// 从数据库加载角色, 表包含 name, inherits, permissions 列, 后两者以逗号分隔
+func (r *RBAC) LoadDB(ctx context.Context, appctx *AppContext, table string) error {
+	var rows []rbacRoleRow
+	cq := NewCrudQuery(table, []string{"name", "inherits", "permissions"}, &rows)
+	if err := appctx.DBQueryContext(ctx, cq); err != nil {
+		return err
+	}
+	roles := make([]Role, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, Role{
+			Name:        row.Name,
+			Inherits:    splitList(row.Inherits),
+			Permissions: splitList(row.Permissions),
+		})
+	}
+	return r.SetRoles(roles)
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// 角色的全部权限, 包含继承的权限
+func (r *RBAC) Permissions(role string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.perms[role]...)
+}
+
+// 判断角色集合是否拥有权限
+func (r *RBAC) HasPermission(roles []string, perm string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, role := range roles {
+		for _, granted := range r.perms[role] {
+			if MatchPermission(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 通配符权限匹配, 如 user:* 匹配 user:read, *:read 匹配 order:read, * 匹配全部
+func MatchPermission(granted string, required string) bool {
+	gs := strings.Split(granted, ":")
+	rs := strings.Split(required, ":")
+	for i, g := range gs {
+		if g == "*" && i == len(gs)-1 {
+			return true
+		}
+		if i >= len(rs) || (g != "*" && g != rs[i]) {
+			return false
+		}
+	}
+	return len(gs) == len(rs)
+}
+
+// 权限检查中间件, 需要拥有全部权限, 角色取自令牌声明, 拒绝时返回 AppError 由 HTTPErrorHandler 输出
+func (r *RBAC) Require(perms ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(perms) == 0 || (r.Skipper != nil && r.Skipper(c)) {
+				return next(c)
+			}
+			claims, ok := auth.FromContext(c)
+			if !ok {
+				return NewUnauthorizedError("未登录或登录已过期")
+			}
+			for _, perm := range perms {
+				if !r.HasPermission(claims.Roles, perm) {
+					return NewForbiddenError("没有访问权限").WithData(perm)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// 注册需要权限的路由
+func (r *RBAC) Handle(g *echo.Group, method string, path string, h echo.HandlerFunc, perms ...string) *echo.Route {
+	route := g.Add(method, path, h, r.Require(perms...))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[route.Method+" "+route.Path] = &RoutePermission{
+		Method:      route.Method,
+		Path:        route.Path,
+		Name:        route.Name,
+		Permissions: perms,
+	}
+	return route
+}
+
+func (r *RBAC) GET(g *echo.Group, path string, h echo.HandlerFunc, perms ...string) *echo.Route {
+	return r.Handle(g, http.MethodGet, path, h, perms...)
+}
+
+func (r *RBAC) POST(g *echo.Group, path string, h echo.HandlerFunc, perms ...string) *echo.Route {
+	return r.Handle(g, http.MethodPost, path, h, perms...)
+}
+
+func (r *RBAC) PUT(g *echo.Group, path string, h echo.HandlerFunc, perms ...string) *echo.Route {
+	return r.Handle(g, http.MethodPut, path, h, perms...)
+}
+
+func (r *RBAC) DELETE(g *echo.Group, path string, h echo.HandlerFunc, perms ...string) *echo.Route {
+	return r.Handle(g, http.MethodDelete, path, h, perms...)
+}
+
+// 全部路由及所需权限, 未声明权限的路由权限为空
+func (r *RBAC) Routes(e *echo.Echo) []RoutePermission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []RoutePermission
+	for _, route := range e.Routes() {
+		if rp, ok := r.routes[route.Method+" "+route.Path]; ok {
+			result = append(result, *rp)
+			continue
+		}
+		result = append(result, RoutePermission{Method: route.Method, Path: route.Path, Name: route.Name})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
+
+// 路由权限列表接口
+func (r *RBAC) RoutesHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, &RestResult{Code: 0, Msgtype: "info", Msg: "success", Data: r.Routes(c.Echo())})
+	}
+}
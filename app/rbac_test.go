@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/auth"
+)
+
+const testRoles = `
+roles:
+  - name: viewer
+    permissions: ["*:read"]
+  - name: editor
+    inherits: [viewer]
+    permissions: ["order:*"]
+  - name: admin
+    permissions: ["*"]
+`
+
+func TestRBACPermissions(t *testing.T) {
+	r := NewRBAC()
+	if err := r.LoadYAML([]byte(testRoles)); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		role string
+		perm string
+		ok   bool
+	}{
+		{"viewer", "user:read", true},
+		{"viewer", "user:write", false},
+		{"editor", "user:read", true},
+		{"editor", "order:refund:all", true},
+		{"editor", "user:write", false},
+		{"admin", "user:write", true},
+		{"guest", "user:read", false},
+	}
+	for _, c := range cases {
+		if r.HasPermission([]string{c.role}, c.perm) != c.ok {
+			t.Fatalf("%s %s expected %v", c.role, c.perm, c.ok)
+		}
+	}
+	if err := r.SetRoles([]Role{{Name: "a", Inherits: []string{"b"}}, {Name: "b", Inherits: []string{"a"}}}); err == nil {
+		t.Fatal("expected inheritance cycle error")
+	}
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	r := NewRBAC()
+	if err := r.LoadYAML([]byte(testRoles)); err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(false)
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if role := c.Request().Header.Get("X-Role"); role != "" {
+				c.Set(auth.ContextKey, &auth.Claims{Roles: []string{role}})
+			}
+			return next(c)
+		}
+	})
+	g := e.Group("")
+	r.DELETE(g, "/user/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, "user:delete")
+	r.GET(g, RBACRoutesPath, r.RoutesHandler(), RBACRoutesPermission)
+	g.GET("/public", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	do := func(method, path, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodDelete, "/user/1", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	rec := do(http.MethodDelete, "/user/1", "editor")
+	var result RestResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusForbidden || result.Code != http.StatusForbidden || result.Data != "user:delete" {
+		t.Fatalf("expected 403, got %d %+v", rec.Code, result)
+	}
+	if rec := do(http.MethodDelete, "/user/1", "admin"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, RBACRoutesPath, "admin")
+	var routes struct {
+		Data []RoutePermission `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &routes)
+	if len(routes.Data) != 3 || routes.Data[0].Path != "/public" || len(routes.Data[0].Permissions) != 0 ||
+		routes.Data[2].Path != "/user/:id" || routes.Data[2].Permissions[0] != "user:delete" {
+		t.Fatalf("unexpected routes %+v", routes.Data)
+	}
+}
+
+func TestRBACLoadDB(t *testing.T) {
+	m := newSqliteAppContext(t)
+	m.Context.DBPool().MustExec(`CREATE TABLE sys_role (name TEXT, inherits TEXT, permissions TEXT)`)
+	m.Context.DBPool().MustExec(`INSERT INTO sys_role VALUES ('viewer', '', 'user:read'), ('editor', 'viewer', 'user:write, order:*')`)
+	r := NewRBAC()
+	if err := r.LoadDB(context.Background(), m, "sys_role"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasPermission([]string{"editor"}, "user:read") || !r.HasPermission([]string{"editor"}, "order:create") {
+		t.Fatalf("unexpected permissions %v", r.Permissions("editor"))
+	}
+}
@@ -20,7 +20,7 @@ func ServerRecover(debug bool) echo.MiddlewareFunc {
 						err = fmt.Errorf("%v", r)
 					}
 					if debug {
-						log.Errorf("%+v", r)
+						log.ErrorfCtx(c.Request().Context(), "%+v", r)
 					}
 					c.Error(err)
 				}
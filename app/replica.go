@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ca17/go-common/conf"
+	"github.com/ca17/go-common/log"
+)
+
+// 支持读写分离的 ContextManager 实现该接口, DBPool 作为主库连接池.
+// ContextManager 嵌入 *DBCluster 即可实现
+type ReaderPoolProvider interface {
+	ReaderPool() *sqlx.DB
+}
+
+type primaryContextKey struct{}
+
+// 强制查询使用主库, 适用于写后立即读的场景. 事务内的查询总是使用主库
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func isUsePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryContextKey{}).(bool)
+	return v
+}
+
+// 查询使用的连接池
+func (m *AppContext) ReaderPool(ctx context.Context) *sqlx.DB {
+	if !isUsePrimary(ctx) {
+		if p, ok := m.Context.(ReaderPoolProvider); ok {
+			if pool := p.ReaderPool(); pool != nil {
+				return pool
+			}
+		}
+	}
+	return m.Context.DBPool()
+}
+
+type replicaPool struct {
+	db      *sqlx.DB
+	healthy int32
+}
+
+// 主从数据库集群, 读请求在健康的副本间轮询, 无可用副本时使用主库.
+// 实现 DBPool 与 ReaderPool, 可嵌入 ContextManager 使 AppContext 的查询使用副本
+type DBCluster struct {
+	primary  *sqlx.DB
+	replicas []*replicaPool
+	next     uint32
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+func NewDBCluster(primary *sqlx.DB, replicas ...*sqlx.DB) *DBCluster {
+	c := &DBCluster{primary: primary, stop: make(chan struct{})}
+	for _, db := range replicas {
+		c.replicas = append(c.replicas, &replicaPool{db: db, healthy: 1})
+	}
+	return c
+}
+
+// 根据配置创建集群并启动副本健康检查
+func GetDBCluster(config *conf.DBConfig) *DBCluster {
+	var replicas []*sqlx.DB
+	for i := range config.Replicas {
+		replicas = append(replicas, GetDatabase(config.ReplicaConfig(i)))
+	}
+	c := NewDBCluster(GetDatabase(config), replicas...)
+	interval := time.Duration(config.HealthCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	c.StartHealthCheck(interval)
+	return c
+}
+
+func (c *DBCluster) Writer() *sqlx.DB {
+	return c.primary
+}
+
+// 主库连接池, 同 Writer
+func (c *DBCluster) DBPool() *sqlx.DB {
+	return c.primary
+}
+
+// 实现 ReaderPoolProvider, 同 Reader
+func (c *DBCluster) ReaderPool() *sqlx.DB {
+	return c.Reader()
+}
+
+func (c *DBCluster) Reader() *sqlx.DB {
+	n := len(c.replicas)
+	if n == 0 {
+		return c.primary
+	}
+	start := atomic.AddUint32(&c.next, 1)
+	for i := 0; i < n; i++ {
+		r := c.replicas[(int(start)+i)%n]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// 检查全部副本, 不可用的副本暂停使用, 恢复后重新加入
+func (c *DBCluster) CheckReplicas(ctx context.Context) {
+	for i, r := range c.replicas {
+		pctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := r.db.PingContext(pctx)
+		cancel()
+		if err != nil {
+			if atomic.SwapInt32(&r.healthy, 0) == 1 {
+				log.Warningf("db replica %d unhealthy, %s", i, err.Error())
+			}
+		} else if atomic.SwapInt32(&r.healthy, 1) == 0 {
+			log.Infof("db replica %d recovered", i)
+		}
+	}
+}
+
+func (c *DBCluster) StartHealthCheck(interval time.Duration) {
+	if len(c.replicas) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckReplicas(context.Background())
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// 停止健康检查并关闭全部连接池
+func (c *DBCluster) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	var err error
+	for _, r := range c.replicas {
+		if cerr := r.db.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	if cerr := c.primary.Close(); cerr != nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ca17/go-common/conf"
+)
+
+func TestDBCluster(t *testing.T) {
+	primary := GetDatabase(&conf.DBConfig{Type: DialectSqlite, Name: ":memory:"})
+	r1 := GetDatabase(&conf.DBConfig{Type: DialectSqlite, Name: ":memory:"})
+	r2 := GetDatabase(&conf.DBConfig{Type: DialectSqlite, Name: ":memory:"})
+	c := NewDBCluster(primary, r1, r2)
+	defer c.Close()
+
+	if c.Reader() == c.Reader() {
+		t.Fatal("reader should round robin replicas")
+	}
+	r1.Close()
+	c.CheckReplicas(context.Background())
+	for i := 0; i < 3; i++ {
+		if c.Reader() != r2 {
+			t.Fatal("unhealthy replica should be skipped")
+		}
+	}
+	r2.Close()
+	c.CheckReplicas(context.Background())
+	if c.Reader() != primary {
+		t.Fatal("reader should fall back to primary")
+	}
+}
+
+var _ ReaderPoolProvider = (*DBCluster)(nil)
+
+// 嵌入 DBCluster 的 ContextManager
+type clusterContextManager struct {
+	*DBCluster
+	testContextManager
+}
+
+func (c *clusterContextManager) DBPool() *sqlx.DB { return c.DBCluster.DBPool() }
+
+// AppContext 的查询使用副本, 事务内与 UsePrimary 时使用主库
+func TestDBClusterAppContext(t *testing.T) {
+	ctx := context.Background()
+	var dbs []*sqlx.DB
+	for _, name := range []string{"primary", "replica"} {
+		db := GetDatabase(&conf.DBConfig{Type: DialectSqlite, Name: ":memory:", MaxConn: 1, MaxIdle: 1})
+		db.MustExec("CREATE TABLE product (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+		db.MustExec("INSERT INTO product (id, name) VALUES (1, ?)", name)
+		dbs = append(dbs, db)
+	}
+	c := NewDBCluster(dbs[0], dbs[1])
+	defer c.Close()
+	m := NewAppContext(&clusterContextManager{DBCluster: c, testContextManager: testContextManager{values: map[string]interface{}{}}})
+
+	query := func(ctx context.Context) string {
+		var names []string
+		if err := m.DBQueryContext(ctx, NewCrudQuery("product", []string{"name"}, &names)); err != nil || len(names) != 1 {
+			t.Fatalf("query %v %v", names, err)
+		}
+		var name string
+		if err := m.DBGetContext(ctx, &CrudGet{Table: "product", Culumns: []string{"name"}, Filter: map[string]interface{}{"id": 1}, ResultRef: &name}); err != nil {
+			t.Fatal(err)
+		}
+		if name != names[0] {
+			t.Fatalf("query %s and get %s used different pools", names[0], name)
+		}
+		return name
+	}
+	if name := query(ctx); name != "replica" {
+		t.Fatalf("expected replica read, got %s", name)
+	}
+	if name := query(UsePrimary(ctx)); name != "primary" {
+		t.Fatalf("expected primary read, got %s", name)
+	}
+	err := m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if name := query(ctx); name != "primary" {
+			t.Fatalf("expected read in transaction on primary, got %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/ca17/go-common/common"
+)
+
+// 实现该接口的模型使用自定义表名, 否则使用类型名的下划线格式
+type TableNamer interface {
+	TableName() string
+}
+
+// 模型表结构元数据, 由 `db` 标签解析
+type TableMeta struct {
+	Table   string
+	Columns []string
+	// 列名到字段索引路径, 支持匿名嵌入结构
+	fields map[string][]int
+}
+
+// 主键列名
+const PrimaryKey = "id"
+
+func (tm *TableMeta) HasColumn(name string) bool {
+	_, ok := tm.fields[name]
+	return ok
+}
+
+// 读取模型字段值, 列顺序与 Columns 一致
+func (tm *TableMeta) values(v reflect.Value, columns []string) []interface{} {
+	vals := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		vals = append(vals, v.FieldByIndex(tm.fields[col]).Interface())
+	}
+	return vals
+}
+
+var tableMetaCache sync.Map
+
+// 获取模型元数据, 每种类型只解析一次, 模型必须为结构体
+func GetTableMeta(t reflect.Type) (*TableMeta, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v, ok := tableMetaCache.Load(t); ok {
+		return v.(*TableMeta), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("table model %s is not a struct", t)
+	}
+	tm := &TableMeta{fields: make(map[string][]int)}
+	if namer, ok := reflect.New(t).Interface().(TableNamer); ok {
+		tm.Table = namer.TableName()
+	} else {
+		tm.Table = common.ToSnakeCase(t.Name())
+	}
+	parseTableFields(t, nil, tm)
+	v, _ := tableMetaCache.LoadOrStore(t, tm)
+	return v.(*TableMeta), nil
+}
+
+func parseTableFields(t reflect.Type, index []int, tm *TableMeta) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		path := append(append([]int{}, index...), i)
+		tag := strings.Split(f.Tag.Get("db"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			parseTableFields(f.Type, path, tm)
+			continue
+		}
+		if tag == "" || f.PkgPath != "" {
+			continue
+		}
+		if _, ok := tm.fields[tag]; ok {
+			continue
+		}
+		tm.fields[tag] = path
+		tm.Columns = append(tm.Columns, tag)
+	}
+}
+
+// 泛型数据仓库, 基于 CrudGet/CrudQuery 提供类型化的 CRUD
+type Repository[T any] struct {
+	appctx *AppContext
+	meta   *TableMeta
+}
+
+func NewRepository[T any](appctx *AppContext) (*Repository[T], error) {
+	meta, err := GetTableMeta(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &Repository[T]{appctx: appctx, meta: meta}, nil
+}
+
+func (r *Repository[T]) Meta() *TableMeta {
+	return r.meta
+}
+
+// 创建预置表名与字段的查询对象
+func (r *Repository[T]) Query() *CrudQuery {
+	return NewCrudQuery(r.meta.Table, r.meta.Columns, nil)
+}
+
+// 获取单个对象
+func (r *Repository[T]) Get(ctx context.Context, filter map[string]interface{}) (T, error) {
+	var item T
+	if err := r.checkColumns(filter); err != nil {
+		return item, err
+	}
+	err := r.appctx.DBGetContext(ctx, NewCrudGet(r.meta.Table, r.meta.Columns, filter, &item))
+	return item, err
+}
+
+// 查询列表, cq 可以为 nil
+func (r *Repository[T]) List(ctx context.Context, cq *CrudQuery) ([]T, error) {
+	items := make([]T, 0)
+	cq = r.prepare(cq, &items)
+	if err := r.appctx.DBQueryContext(ctx, cq); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 分页查询
+func (r *Repository[T]) Page(ctx context.Context, cq *CrudQuery) ([]T, *PageResult, error) {
+	items := make([]T, 0)
+	cq = r.prepare(cq, &items)
+	cq.Pager = true
+	if err := r.appctx.DBQueryContext(ctx, cq); err != nil {
+		return nil, EmptyPageResult, err
+	}
+	return items, cq.ResultPage, nil
+}
+
+// 新增对象, 主键为零值时由数据库生成
+func (r *Repository[T]) Insert(ctx context.Context, item T) error {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	vals := make(map[string]interface{}, len(r.meta.Columns))
+	for _, col := range r.meta.Columns {
+		fv := v.FieldByIndex(r.meta.fields[col])
+		if col == PrimaryKey && fv.IsZero() {
+			continue
+		}
+		vals[col] = fv.Interface()
+	}
+	return r.appctx.DBInsertContext(ctx, r.meta.Table, vals)
+}
+
+// 根据主键更新对象, columns 为空时更新全部非主键字段
+func (r *Repository[T]) Update(ctx context.Context, item T, columns ...string) (int64, error) {
+	if !r.meta.HasColumn(PrimaryKey) {
+		return 0, fmt.Errorf("table %s has no %s column", r.meta.Table, PrimaryKey)
+	}
+	if len(columns) == 0 {
+		for _, col := range r.meta.Columns {
+			if col != PrimaryKey {
+				columns = append(columns, col)
+			}
+		}
+	}
+	for _, col := range columns {
+		if !r.meta.HasColumn(col) {
+			return 0, fmt.Errorf("table %s has no column %s", r.meta.Table, col)
+		}
+	}
+	v := reflect.Indirect(reflect.ValueOf(item))
+	vals := make(map[string]interface{}, len(columns))
+	for i, val := range r.meta.values(v, columns) {
+		vals[columns[i]] = val
+	}
+	pk := v.FieldByIndex(r.meta.fields[PrimaryKey]).Interface()
+	return r.appctx.DBUpdateContext(ctx, NewCrudUpdate(r.meta.Table, vals, sq.Eq{PrimaryKey: pk}))
+}
+
+// 根据主键删除
+func (r *Repository[T]) Delete(ctx context.Context, ids []string) (int64, error) {
+	return r.appctx.DBDeleteContext(ctx, r.meta.Table, ids)
+}
+
+// 根据条件删除
+func (r *Repository[T]) DeleteWithFilter(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	if err := r.checkColumns(filter); err != nil {
+		return 0, err
+	}
+	return r.appctx.DBDeleteWithFilterContext(ctx, r.meta.Table, filter)
+}
+
+func (r *Repository[T]) prepare(cq *CrudQuery, resultRef interface{}) *CrudQuery {
+	if cq == nil {
+		cq = r.Query()
+	}
+	if cq.Table == "" {
+		cq.Table = r.meta.Table
+	}
+	if len(cq.Culumns) == 0 {
+		cq.Culumns = r.meta.Columns
+	}
+	cq.ResultRef = resultRef
+	return cq
+}
+
+// 校验过滤条件中的列名, 防止拼写错误
+func (r *Repository[T]) checkColumns(filter map[string]interface{}) error {
+	for name := range filter {
+		if !r.meta.HasColumn(name) {
+			return fmt.Errorf("table %s has no column %s", r.meta.Table, name)
+		}
+	}
+	return nil
+}
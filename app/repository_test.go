@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testBase struct {
+	Id int64 `db:"id"`
+}
+
+type testUserInfo struct {
+	testBase
+	Name    string `db:"name"`
+	Remark  string `db:"-"`
+	Mobile  string `db:"mobile"`
+	Ignored string
+}
+
+type testNamedTable struct {
+	Id int64 `db:"id"`
+}
+
+func (testNamedTable) TableName() string {
+	return "tbl_named"
+}
+
+func TestGetTableMeta(t *testing.T) {
+	tm, err := GetTableMeta(reflect.TypeOf(testUserInfo{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm.Table != "test_user_info" {
+		t.Fatalf("unexpected table %s", tm.Table)
+	}
+	if !reflect.DeepEqual(tm.Columns, []string{"id", "name", "mobile"}) {
+		t.Fatalf("unexpected columns %v", tm.Columns)
+	}
+	if tm, _ = GetTableMeta(reflect.TypeOf(&testNamedTable{})); tm.Table != "tbl_named" {
+		t.Fatal("TableName not used")
+	}
+	if _, err = GetTableMeta(reflect.TypeOf(map[string]interface{}{})); err == nil {
+		t.Fatal("expected error for non-struct model")
+	}
+	if _, err = NewRepository[string](nil); err == nil {
+		t.Fatal("expected error for non-struct repository")
+	}
+}
+
+func TestRepositoryCrud(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepository[testProduct](newSqliteAppContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"apple", "banana", "cherry"} {
+		if err = repo.Insert(ctx, testProduct{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = repo.Insert(ctx, testProduct{Id: 10, Name: "durian"}); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := repo.Get(ctx, map[string]interface{}{"name": "banana"})
+	if err != nil || item.Id != 2 || item.Name != "banana" {
+		t.Fatalf("get %+v %v", item, err)
+	}
+	if item, err = repo.Get(ctx, map[string]interface{}{"id": 10}); err != nil || item.Name != "durian" {
+		t.Fatalf("get by explicit id %+v %v", item, err)
+	}
+	if _, err = repo.Get(ctx, map[string]interface{}{"name": "none"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected no rows, got %v", err)
+	}
+	if _, err = repo.Get(ctx, map[string]interface{}{"nmae": "apple"}); err == nil {
+		t.Fatal("expected unknown column error")
+	}
+
+	items, err := repo.List(ctx, nil)
+	if err != nil || len(items) != 4 {
+		t.Fatalf("list %v %v", items, err)
+	}
+
+	cq := repo.Query()
+	cq.PageSize = 2
+	cq.PagePos = 2
+	cq.OrderBy = "id"
+	cq.CountMode = CountAlways
+	page, result, err := repo.Page(ctx, cq)
+	if err != nil || len(page) != 2 || page[0].Name != "cherry" || result.TotalCount != 4 {
+		t.Fatalf("page %v %+v %v", page, result, err)
+	}
+
+	item.Tags = "yellow"
+	item.Version = 2
+	if n, err := repo.Update(ctx, item, "tags"); err != nil || n != 1 {
+		t.Fatalf("update %d %v", n, err)
+	}
+	if item, _ = repo.Get(ctx, map[string]interface{}{"id": 10}); item.Tags != "yellow" || item.Version != 0 {
+		t.Fatalf("update should only write tags, got %+v", item)
+	}
+	item.Name = "durian2"
+	item.Version = 2
+	if n, err := repo.Update(ctx, item); err != nil || n != 1 {
+		t.Fatalf("update all %d %v", n, err)
+	}
+	if item, _ = repo.Get(ctx, map[string]interface{}{"id": 10}); item.Name != "durian2" || item.Version != 2 {
+		t.Fatalf("update all columns, got %+v", item)
+	}
+	if _, err = repo.Update(ctx, item, "nmae"); err == nil {
+		t.Fatal("expected unknown column error")
+	}
+
+	if n, err := repo.Delete(ctx, []string{"1", "2"}); err != nil || n != 2 {
+		t.Fatalf("delete %d %v", n, err)
+	}
+	if n, err := repo.DeleteWithFilter(ctx, map[string]interface{}{"name": "cherry"}); err != nil || n != 1 {
+		t.Fatalf("delete with filter %d %v", n, err)
+	}
+	if items, _ = repo.List(ctx, nil); len(items) != 1 || items[0].Id != 10 {
+		t.Fatalf("unexpected rows after delete %v", items)
+	}
+}
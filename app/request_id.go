@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/ca17/go-common/auth"
+	"github.com/ca17/go-common/common"
+	"github.com/ca17/go-common/conf"
+	"github.com/ca17/go-common/log"
+)
+
+// 访问日志格式
+const (
+	AccessLogText = "text"
+	AccessLogJSON = "json"
+	AccessLogOff  = "off"
+)
+
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// 读取或生成 X-Request-ID, 写入响应头与请求 context,
+// 使用 c.Request().Context() 调用 log 包的 *Ctx 函数时日志自动带上请求 ID
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !requestIDRegexp.MatchString(id) {
+				id = strings.ReplaceAll(common.UUID(), "-", "")
+				req.Header.Set(echo.HeaderXRequestID, id)
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			c.SetRequest(req.WithContext(log.ContextWithRequestID(req.Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// 读取请求 ID
+func RequestIDFromContext(ctx context.Context) string {
+	return log.RequestIDFromContext(ctx)
+}
+
+// JSON 访问日志条目
+type AccessLogEntry struct {
+	Time      string  `json:"time"`
+	App       string  `json:"app,omitempty"`
+	ID        string  `json:"id,omitempty"`
+	RemoteIP  string  `json:"remote_ip"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Route     string  `json:"route"`
+	Protocol  string  `json:"protocol"`
+	Status    int     `json:"status"`
+	Latency   float64 `json:"latency_ms"`
+	BytesIn   int64   `json:"bytes_in"`
+	BytesOut  int64   `json:"bytes_out"`
+	UserID    string  `json:"user_id,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// JSON 格式访问日志, 每个请求输出一行
+func JSONAccessLogger(app string, output io.Writer) echo.MiddlewareFunc {
+	var mu sync.Mutex
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			req := c.Request()
+			res := c.Response()
+			entry := AccessLogEntry{
+				Time:      start.Format(time.RFC3339),
+				App:       app,
+				ID:        res.Header().Get(echo.HeaderXRequestID),
+				RemoteIP:  c.RealIP(),
+				Method:    req.Method,
+				URI:       req.RequestURI,
+				Route:     c.Path(),
+				Protocol:  req.Proto,
+				Status:    res.Status,
+				Latency:   float64(time.Since(start).Microseconds()) / 1000,
+				BytesOut:  res.Size,
+				UserAgent: req.UserAgent(),
+			}
+			if cl := req.Header.Get(echo.HeaderContentLength); cl != "" {
+				entry.BytesIn, _ = strconv.ParseInt(cl, 10, 64)
+			}
+			if claims, ok := auth.FromContext(c); ok {
+				entry.UserID = claims.UserID()
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			line, jerr := json.Marshal(entry)
+			if jerr != nil {
+				return err
+			}
+			mu.Lock()
+			output.Write(append(line, '\n'))
+			mu.Unlock()
+			// 错误已经处理
+			return nil
+		}
+	}
+}
+
+// 按 WebConfig.AccessLog 创建访问日志中间件, off 时返回 nil
+func accessLogger(config conf.AppConfig) echo.MiddlewareFunc {
+	switch config.GetWebConfig().AccessLog {
+	case AccessLogOff:
+		return nil
+	case AccessLogJSON:
+		return JSONAccessLogger(config.GetAppName(), os.Stdout)
+	}
+	return middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format: config.GetAppName() + " ${time_rfc3339} ${remote_ip} ${method} ${uri} ${protocol} ${status} ${id} ${user_agent} ${error}\n",
+		Output: os.Stdout,
+	})
+}
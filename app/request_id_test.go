@@ -0,0 +1,50 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	e := echo.New()
+	e.Use(RequestIDMiddleware())
+	e.Use(JSONAccessLogger("test", &out))
+	var ctxID string
+	e.GET("/users/:id", func(c echo.Context) error {
+		ctxID = RequestIDFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc-123")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if got := rec.Header().Get(echo.HeaderXRequestID); got != "abc-123" {
+		t.Fatalf("response id %q", got)
+	}
+	if ctxID != "abc-123" {
+		t.Fatalf("ctx id %q", ctxID)
+	}
+	var entry AccessLogEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID != "abc-123" || entry.Route != "/users/:id" || entry.Status != http.StatusOK || entry.BytesOut != 2 {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	// 非法的请求 ID 重新生成
+	req = httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set(echo.HeaderXRequestID, "bad id\n")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if got := rec.Header().Get(echo.HeaderXRequestID); got == "" || got == "bad id\n" {
+		t.Fatalf("generated id %q", got)
+	}
+}
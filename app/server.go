@@ -1,74 +1,439 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"os"
+	"net"
+	"net/http"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
-	"github.com/ca17/go-common/common"
+	"github.com/ca17/go-common/auth"
 	"github.com/ca17/go-common/conf"
 	"github.com/ca17/go-common/log"
 	"github.com/ca17/go-common/tpl"
 )
 
-func StartWebserver(config conf.AppConfig, appContext *AppContext, tplrender *tpl.CommonTemplate, handler ...WebHandler) error {
+// 默认优雅关闭等待时间
+const DefaultShutdownTimeout = 10 * time.Second
+
+// TLS 配置错误
+var ErrTLSConfig = errors.New("tls config error")
+
+// 生命周期钩子, 启动时按注册顺序执行, 停止时按注册逆序执行
+type LifecycleHook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Web 服务, 支持信号处理与优雅关闭
+type Server struct {
+	Echo            *echo.Echo
+	Health          *HealthRegistry
+	RBAC            *RBAC
+	AuthSkip        *SkipRules
+	ShutdownTimeout time.Duration
+
+	config   conf.AppConfig
+	appctx   *AppContext
+	mu       sync.Mutex
+	hooks    []LifecycleHook
+	running  []LifecycleHook
+	startRan bool
+	stopOnce sync.Once
+	stopErr  error
+	initErr  error
+}
+
+func NewServer(config conf.AppConfig, appContext *AppContext, tplrender *tpl.CommonTemplate, handler ...WebHandler) *Server {
 	webcfg := config.GetWebConfig()
-	e := echo.New()
+	s := &Server{
+		Echo:            echo.New(),
+		Health:          NewHealthRegistry(),
+		RBAC:            rbacManager(appContext),
+		AuthSkip:        NewSkipRules(),
+		ShutdownTimeout: DefaultShutdownTimeout,
+		config:          config,
+		appctx:          appContext,
+	}
+	if webcfg.ShutdownTimeout > 0 {
+		s.ShutdownTimeout = time.Duration(webcfg.ShutdownTimeout) * time.Second
+	}
+	// 资源最先注册, 停止时最后关闭
+	s.Append(ResourceHooks(appContext)...)
+	s.Health.RegisterResources(appContext)
+
+	e := s.Echo
+	e.HTTPErrorHandler = HTTPErrorHandler(webcfg.Debug)
+	if extractor, err := ipExtractor(webcfg.TrustedProxies); err != nil {
+		s.initErr = err
+	} else {
+		e.IPExtractor = extractor
+	}
 	e.Pre(middleware.RemoveTrailingSlash())
 	// e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 	// 	Level: 5,
 	// }))
+	e.Use(RequestIDMiddleware())
 	e.Use(ServerRecover(config.GetWebConfig().Debug))
-	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: config.GetAppName()+" ${time_rfc3339} ${remote_ip} ${method} ${uri} ${protocol} ${status} ${id} ${user_agent} ${error}\n",
-		Output: os.Stdout,
-	}))
+	if webcfg.MetricsPath != "" {
+		metrics := NewMetrics(appContext)
+		e.Use(metrics.Middleware())
+		if webcfg.MetricsPort > 0 {
+			s.Append(metricsServerHook(webcfg, metrics))
+		} else {
+			e.GET(webcfg.MetricsPath, metrics.Handler())
+		}
+	}
+	if logger := accessLogger(config); logger != nil {
+		e.Use(logger)
+	}
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: strings.Split(webcfg.AllowOrigins, ","),
 		AllowMethods: []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
 		// AllowHeaders: []string{"Content-Type"},
 		AllowCredentials: true,
 	}))
-	e.Use(middleware.JWTWithConfig(middleware.JWTConfig{
-		SigningKey: []byte(webcfg.Secret),
-		Skipper: func(c echo.Context) bool {
-			if config.IsDev() {
-				return true
-			}
-			skips := strings.Split(webcfg.AuthSkip, ",")
-			if common.InSlice(c.Request().RequestURI, skips) {
-				return true
-			}
-			skipPrefix, ok := appContext.Get(AuthSkipPrefix)
-			if ok {
-				for _, p := range skipPrefix.([]string) {
-					if strings.HasPrefix(c.Request().RequestURI, p) {
-						return true
-					}
-				}
-			}
-			return false
-		},
-	}))
+	s.AuthSkip.Add(SkipSourceBuiltin,
+		SkipRule{Method: http.MethodGet, Path: HealthzPath},
+		SkipRule{Method: http.MethodGet, Path: ReadyzPath})
+	if webcfg.MetricsPath != "" && webcfg.MetricsPort == 0 {
+		s.AuthSkip.Add(SkipSourceBuiltin, SkipRule{Method: http.MethodGet, Path: webcfg.MetricsPath})
+	}
+	if err := s.AuthSkip.AddList(SkipSourceConfig, webcfg.AuthSkip); err != nil {
+		s.initErr = err
+	}
+	s.AuthSkip.Add(SkipSourceContext, contextSkipRules(appContext)...)
+	skipper := func(c echo.Context) bool {
+		return config.IsDev() || s.AuthSkip.Match(c)
+	}
+	if manager := authManager(appContext); manager != nil {
+		e.Use(manager.Middleware(skipper))
+	} else {
+		e.Use(middleware.JWTWithConfig(middleware.JWTConfig{
+			SigningKey: []byte(webcfg.Secret),
+			Skipper:    skipper,
+		}))
+	}
+	e.Use(ActorMiddleware())
+
+	if len(webcfg.RateLimits) > 0 {
+		store, err := rateLimitStore(config, appContext)
+		var limiter echo.MiddlewareFunc
+		if err == nil {
+			limiter, err = rateLimitFromConfig(webcfg, store)
+		}
+		if err != nil {
+			s.initErr = err
+		} else {
+			e.Use(limiter)
+		}
+	}
+
+	e.GET(HealthzPath, s.Health.LivenessHandler())
+	e.GET(ReadyzPath, s.Health.ReadinessHandler())
+
+	if config.IsDev() {
+		s.RBAC.Skipper = func(c echo.Context) bool { return true }
+	}
 
 	// Init Handlers
 	webctx := NewWebContext(appContext, &config)
+	webctx.RBAC = s.RBAC
 	group := e.Group("")
+	s.RBAC.GET(group, RBACRoutesPath, s.RBAC.RoutesHandler(), RBACRoutesPermission)
 	for _, webHandler := range handler {
 		webHandler.InitRouter(webctx, group)
+		if skipper, ok := webHandler.(AuthSkipper); ok {
+			s.AuthSkip.Add(SkipSourceHandler, skipper.AuthSkipRules()...)
+		}
+	}
+	if webcfg.Debug {
+		log.Infof("auth skip rules:\n%s", s.AuthSkip.Dump())
+	}
+	if tplrender != nil {
+		e.Renderer = tplrender
 	}
-	e.Renderer = tplrender
 	e.HideBanner = true
 	e.Debug = webcfg.Debug
-	log.Info("try start tls server")
-	err := e.StartTLS(fmt.Sprintf("%s:%d", webcfg.Host, webcfg.Port), webcfg.CertFile, webcfg.KeyFile)
+	return s
+}
+
+// 启动 Web 服务并阻塞, 收到 SIGTERM 或 SIGINT 后优雅关闭
+func StartWebserver(config conf.AppConfig, appContext *AppContext, tplrender *tpl.CommonTemplate, handler ...WebHandler) error {
+	return NewServer(config, appContext, tplrender, handler...).Run()
+}
+
+// 客户端 IP 提取方式, 未配置可信代理时忽略 X-Forwarded-For 与 X-Real-IP,
+// 避免客户端伪造请求头绕过按 IP 的限流
+func ipExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	var options []echo.TrustOption
+	for _, item := range strings.Split(trustedProxies, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		options = append(options, echo.TrustIPRange(ipnet))
+	}
+	if len(options) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+func authManager(appContext *AppContext) *auth.Manager {
+	if appContext == nil {
+		return nil
+	}
+	if v, ok := appContext.Get(AuthManager); ok {
+		return v.(*auth.Manager)
+	}
+	return nil
+}
+
+// 限流计数存储, 优先使用上下文中设置的存储
+func rateLimitStore(config conf.AppConfig, appContext *AppContext) (RateLimitStore, error) {
+	if appContext != nil {
+		if v, ok := appContext.Get(RateLimiterStore); ok {
+			return v.(RateLimitStore), nil
+		}
+	}
+	if config.GetWebConfig().RateLimitStore == "redis" {
+		rediscfg := config.GetRedisConfig()
+		if rediscfg == nil {
+			return nil, errors.New("rate limit store redis requires redis config")
+		}
+		return NewRedisRateLimitStore(NewRedisClient(rediscfg), config.GetAppName()+":"), nil
+	}
+	return NewMemoryRateLimitStore(), nil
+}
+
+func rbacManager(appContext *AppContext) *RBAC {
+	if appContext != nil {
+		if v, ok := appContext.Get(RBACManager); ok {
+			return v.(*RBAC)
+		}
+	}
+	return NewRBAC()
+}
+
+// 注册生命周期钩子
+func (s *Server) Append(hooks ...LifecycleHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hooks...)
+}
+
+// 注册启动钩子
+func (s *Server) OnStart(name string, fn func(ctx context.Context) error) {
+	s.Append(LifecycleHook{Name: name, OnStart: fn})
+}
+
+// 注册停止钩子
+func (s *Server) OnStop(name string, fn func(ctx context.Context) error) {
+	s.Append(LifecycleHook{Name: name, OnStop: fn})
+}
+
+// 执行启动钩子并启动监听, 阻塞直到服务关闭. 调用 Shutdown 后返回 nil
+func (s *Server) Start() error {
+	if s.initErr != nil {
+		return s.initErr
+	}
+	webcfg := s.config.GetWebConfig()
+	addr := fmt.Sprintf("%s:%d", webcfg.Host, webcfg.Port)
+	useTLS, err := s.checkTLS(webcfg)
 	if err != nil {
-		log.Warningf("start tls server error %+v", err)
-		log.Info("start server")
-		err = e.Start(fmt.Sprintf("%s:%d", webcfg.Host, webcfg.Port))
+		return err
+	}
+	if err = s.runStartHooks(context.Background()); err != nil {
+		return err
+	}
+	if useTLS {
+		log.Infof("start tls server %s", addr)
+		err = s.Echo.StartTLS(addr, webcfg.CertFile, webcfg.KeyFile)
+	} else {
+		log.Infof("start server %s", addr)
+		err = s.Echo.Start(addr)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
 	return err
 }
+
+// 启动服务并等待退出信号, 收到信号或服务异常退出后执行 Shutdown
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		log.Info("received shutdown signal")
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if serr := s.Shutdown(shutdownCtx); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// 停止接收新请求, 等待处理中的请求完成后逆序执行停止钩子.
+// 超过 ctx 期限时强制关闭连接, 可重复调用
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		s.Health.SetShuttingDown()
+		if err := s.Echo.Shutdown(ctx); err != nil {
+			log.Warningf("shutdown server error %+v", err)
+			s.stopErr = err
+			s.Echo.Close()
+		}
+		if err := s.runStopHooks(ctx); err != nil && s.stopErr == nil {
+			s.stopErr = err
+		}
+		log.Info("server stopped")
+	})
+	return s.stopErr
+}
+
+// 检查 TLS 配置, 未配置证书时使用 HTTP
+func (s *Server) checkTLS(webcfg *conf.WebConfig) (bool, error) {
+	if webcfg.CertFile == "" && webcfg.KeyFile == "" {
+		return false, nil
+	}
+	_, err := tls.LoadX509KeyPair(webcfg.CertFile, webcfg.KeyFile)
+	if err == nil {
+		return true, nil
+	}
+	if webcfg.TLSFallback {
+		log.Warningf("load tls certificate error %+v, fallback to http", err)
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %v", ErrTLSConfig, err)
+}
+
+// 按顺序执行启动钩子, 失败时回滚已启动的钩子
+func (s *Server) runStartHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := append([]LifecycleHook(nil), s.hooks...)
+	s.startRan = true
+	s.mu.Unlock()
+	for _, h := range hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				log.Errorf("start hook %s error %+v", h.Name, err)
+				s.runStopHooks(ctx)
+				return fmt.Errorf("start hook %s: %w", h.Name, err)
+			}
+		}
+		s.mu.Lock()
+		s.running = append(s.running, h)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// 逆序执行已启动的停止钩子, 返回第一个错误.
+// 未执行启动流程时执行全部停止钩子, 以便释放资源
+func (s *Server) runStopHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := s.running
+	if !s.startRan {
+		hooks = s.hooks
+	}
+	s.running = nil
+	s.startRan = true
+	s.mu.Unlock()
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.OnStop == nil {
+			continue
+		}
+		if err := h.OnStop(ctx); err != nil {
+			log.Errorf("stop hook %s error %+v", h.Name, err)
+			if first == nil {
+				first = fmt.Errorf("stop hook %s: %w", h.Name, err)
+			}
+		}
+	}
+	return first
+}
+
+// ContextManager 资源的关闭钩子, 依次为数据库, MongoDB 与 gRPC 连接
+func ResourceHooks(appctx *AppContext) []LifecycleHook {
+	if appctx == nil || appctx.Context == nil {
+		return nil
+	}
+	cm := appctx.Context
+	return []LifecycleHook{
+		{Name: "database", OnStop: func(ctx context.Context) error {
+			if db := cm.DBPool(); db != nil {
+				return db.Close()
+			}
+			return nil
+		}},
+		{Name: "mongodb", OnStop: func(ctx context.Context) error {
+			if client := cm.MongoDb(); client != nil {
+				return client.Disconnect(ctx)
+			}
+			return nil
+		}},
+		{Name: "grpc", OnStop: func(ctx context.Context) error {
+			if conn := cm.GrpConn(); conn != nil {
+				return conn.Close()
+			}
+			return nil
+		}},
+	}
+}
+
+// 指标独立端口服务
+func metricsServerHook(webcfg *conf.WebConfig, metrics *Metrics) LifecycleHook {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.GET(webcfg.MetricsPath, metrics.Handler())
+	return LifecycleHook{
+		Name: "metrics",
+		OnStart: func(ctx context.Context) error {
+			log.Infof("start metrics server %s:%d%s", webcfg.Host, webcfg.MetricsPort, webcfg.MetricsPath)
+			go func() {
+				err := e.Start(fmt.Sprintf("%s:%d", webcfg.Host, webcfg.MetricsPort))
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Errorf("metrics server error %+v", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return e.Shutdown(ctx)
+		},
+	}
+}
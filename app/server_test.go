@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/conf"
+)
+
+type testAppConfig struct {
+	web  conf.WebConfig
+	prod bool
+}
+
+func (c *testAppConfig) GetWebConfig() *conf.WebConfig         { return &c.web }
+func (c *testAppConfig) GetDBConfig() *conf.DBConfig           { return nil }
+func (c *testAppConfig) GetRedisConfig() *conf.RedisConfig     { return nil }
+func (c *testAppConfig) GetGrpcConfig() *conf.GrpcConfig       { return nil }
+func (c *testAppConfig) GetMongodbConfig() *conf.MongodbConfig { return nil }
+func (c *testAppConfig) GetAppName() string                    { return "test" }
+func (c *testAppConfig) GetSyslogAddr() string                 { return "" }
+func (c *testAppConfig) IsDev() bool                           { return !c.prod }
+
+type slowHandler struct {
+	entered chan struct{}
+}
+
+func (h *slowHandler) InitRouter(webctx *WebContext, g *echo.Group) {
+	g.GET("/slow", func(c echo.Context) error {
+		close(h.entered)
+		time.Sleep(200 * time.Millisecond)
+		return c.String(http.StatusOK, "done")
+	})
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	m := newSqliteAppContext(t)
+	handler := &slowHandler{entered: make(chan struct{})}
+	s := NewServer(&testAppConfig{}, m, nil, handler)
+	var events []string
+	for _, name := range []string{"a", "b"} {
+		name := name
+		s.Append(LifecycleHook{
+			Name:    name,
+			OnStart: func(ctx context.Context) error { events = append(events, "start "+name); return nil },
+			OnStop:  func(ctx context.Context) error { events = append(events, "stop "+name); return nil },
+		})
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Echo.Listener = ln
+	startErr := make(chan error, 1)
+	go func() { startErr <- s.Start() }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-handler.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-body; b != "done" {
+		t.Fatalf("request not drained: %s", b)
+	}
+	if err := <-startErr; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, []string{"start a", "start b", "stop b", "stop a"}) {
+		t.Fatalf("unexpected hook order %v", events)
+	}
+	if err := m.Context.DBPool().Ping(); err == nil {
+		t.Fatal("expected database closed")
+	}
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	config := &testAppConfig{web: conf.WebConfig{CertFile: "missing.crt", KeyFile: "missing.key"}}
+	s := NewServer(config, nil, nil)
+	if err := s.Start(); !errors.Is(err, ErrTLSConfig) {
+		t.Fatalf("expected tls config error, got %v", err)
+	}
+	config.web.TLSFallback = true
+	if useTLS, err := s.checkTLS(config.GetWebConfig()); useTLS || err != nil {
+		t.Fatalf("expected http fallback, got %v %v", useTLS, err)
+	}
+}
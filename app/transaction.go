@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ca17/go-common/log"
+)
+
+type txContextKey struct{}
+
+// 当前 context 携带的事务状态, 嵌套调用共享同一事务
+type txState struct {
+	tx  *sqlx.Tx
+	seq int
+}
+
+// 事务回调, ctx 携带当前事务, 回调内的 CRUD 方法自动加入该事务
+type TxFunc func(ctx context.Context, tx *sqlx.Tx) error
+
+// 获取 context 中的事务
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	if st, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return st.tx, true
+	}
+	return nil, false
+}
+
+// 在事务中执行 fn, 返回错误时回滚, 否则提交. fn panic 时回滚后继续抛出 panic.
+// 若 ctx 中已存在事务, 则使用 SAVEPOINT 实现嵌套事务
+func (m *AppContext) WithTx(ctx context.Context, fn TxFunc) (err error) {
+	if st, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return m.withSavepoint(ctx, st, fn)
+	}
+
+	tx, err := m.Context.DBPool().BeginTxx(ctx, nil)
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+	rollback := func() {
+		if rerr := tx.Rollback(); rerr != nil {
+			log.ErrorfCtx(ctx, "rollback error %s", rerr.Error())
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rollback()
+			panic(r)
+		}
+		if err != nil {
+			rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			log.ErrorCtx(ctx, err)
+		}
+	}()
+	return fn(context.WithValue(ctx, txContextKey{}, &txState{tx: tx}), tx)
+}
+
+func (m *AppContext) withSavepoint(ctx context.Context, st *txState, fn TxFunc) (err error) {
+	st.seq++
+	savepoint := fmt.Sprintf("sp_%d", st.seq)
+	if _, err = st.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		log.ErrorCtx(ctx, err)
+		return err
+	}
+	rollback := func() {
+		if _, rerr := st.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rerr != nil {
+			log.ErrorfCtx(ctx, "rollback to savepoint %s error %s", savepoint, rerr.Error())
+			return
+		}
+		// ROLLBACK TO 不会移除保存点, 需要显式释放
+		if _, rerr := st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); rerr != nil {
+			log.ErrorfCtx(ctx, "release savepoint %s error %s", savepoint, rerr.Error())
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rollback()
+			panic(r)
+		}
+		if err != nil {
+			rollback()
+			return
+		}
+		if _, err = st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+			log.ErrorCtx(ctx, err)
+		}
+	}()
+	return fn(ctx, st.tx)
+}
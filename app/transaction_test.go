@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func productNames(t *testing.T, m *AppContext) []string {
+	t.Helper()
+	var names []string
+	if err := m.Context.DBPool().Select(&names, "SELECT name FROM product ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func insertProduct(ctx context.Context, m *AppContext, name string) error {
+	return m.DBInsertContext(ctx, "product", map[string]interface{}{"name": name})
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	m := newSqliteAppContext(t)
+
+	err := m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if _, ok := TxFromContext(ctx); !ok {
+			t.Fatal("expected transaction in context")
+		}
+		return insertProduct(ctx, m, "commit")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errRollback := errors.New("rollback")
+	err = m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := insertProduct(ctx, m, "error"); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected panic to be rethrown, got %v", r)
+			}
+		}()
+		m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			if err := insertProduct(ctx, m, "panic"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if names := productNames(t, m); len(names) != 1 || names[0] != "commit" {
+		t.Fatalf("unexpected rows %v", names)
+	}
+}
+
+// 嵌套事务使用 SAVEPOINT, 内层失败只回滚内层写入
+func TestWithTxSavepoint(t *testing.T) {
+	ctx := context.Background()
+	m := newSqliteAppContext(t)
+
+	err := m.WithTx(ctx, func(ctx context.Context, outer *sqlx.Tx) error {
+		if err := insertProduct(ctx, m, "outer"); err != nil {
+			return err
+		}
+		err := m.WithTx(ctx, func(ctx context.Context, inner *sqlx.Tx) error {
+			if inner != outer {
+				t.Fatal("nested call should share the outer transaction")
+			}
+			if err := insertProduct(ctx, m, "inner"); err != nil {
+				return err
+			}
+			return errors.New("inner failed")
+		})
+		if err == nil {
+			t.Fatal("expected inner error")
+		}
+		err = m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			return insertProduct(ctx, m, "released")
+		})
+		if err != nil {
+			return err
+		}
+		func() {
+			defer func() { recover() }()
+			m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+				insertProduct(ctx, m, "inner panic")
+				panic("boom")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := productNames(t, m)
+	if len(names) != 2 || names[0] != "outer" || names[1] != "released" {
+		t.Fatalf("unexpected rows %v", names)
+	}
+
+	// 外层失败时已释放的保存点一并回滚
+	err = m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			return insertProduct(ctx, m, "nested")
+		}); err != nil {
+			return err
+		}
+		return errors.New("outer failed")
+	})
+	if err == nil {
+		t.Fatal("expected outer error")
+	}
+	if names = productNames(t, m); len(names) != 2 {
+		t.Fatalf("outer rollback should discard nested rows, got %v", names)
+	}
+}
+
+// 回滚到保存点后保存点随即释放, 清空表也走当前事务
+func TestWithTxSavepointRelease(t *testing.T) {
+	ctx := context.Background()
+	m := newSqliteAppContext(t)
+
+	err := m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			return errors.New("inner failed")
+		}); err == nil {
+			t.Fatal("expected inner error")
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT sp_1"); err == nil {
+			t.Fatal("savepoint should be released after rollback")
+		}
+		if err := insertProduct(ctx, m, "truncated"); err != nil {
+			return err
+		}
+		if err := m.DBTrucateContext(ctx, "product"); err != nil {
+			return err
+		}
+		return errors.New("outer failed")
+	})
+	if err == nil {
+		t.Fatal("expected outer error")
+	}
+	if err = insertProduct(ctx, m, "kept"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return m.DBTrucateContext(ctx, "product")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if names := productNames(t, m); len(names) != 0 {
+		t.Fatalf("truncate in transaction should commit, got %v", names)
+	}
+}
@@ -0,0 +1,318 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/go-common/common"
+)
+
+// 过滤操作符
+type FilterOp string
+
+const (
+	OpEq      FilterOp = "eq"
+	OpIn      FilterOp = "in"
+	OpGte     FilterOp = "gte"
+	OpLte     FilterOp = "lte"
+	OpLike    FilterOp = "like"
+	OpBetween FilterOp = "between"
+	OpIsNull  FilterOp = "isnull"
+)
+
+// 默认排序参数名
+const SortParam = "sort"
+
+// 单个过滤条件, 由 FilterSpec 从请求参数生成
+type CrudFilter struct {
+	Column string
+	Op     FilterOp
+	Values []interface{}
+}
+
+// 列名不合法或参数个数不足时返回错误, between 需要两个参数, 其余至少一个
+func (f CrudFilter) toSqlizer(d Dialect) (sq.Sqlizer, error) {
+	if !IsSafeIdentifier(f.Column) {
+		return nil, fmt.Errorf("invalid filter column %s", f.Column)
+	}
+	arity := 1
+	if f.Op == OpBetween {
+		arity = 2
+	}
+	if len(f.Values) < arity {
+		return nil, fmt.Errorf("filter %s %s requires %d values, got %d", f.Column, f.Op, arity, len(f.Values))
+	}
+	switch f.Op {
+	case OpIn:
+		return sq.Eq{f.Column: f.Values}, nil
+	case OpGte:
+		return sq.GtOrEq{f.Column: f.Values[0]}, nil
+	case OpLte:
+		return sq.LtOrEq{f.Column: f.Values[0]}, nil
+	case OpLike:
+		return d.Like(f.Column, "%"+EscapeLike(fmt.Sprint(f.Values[0]))+"%"), nil
+	case OpBetween:
+		and := sq.And{}
+		if f.Values[0] != "" {
+			and = append(and, sq.GtOrEq{f.Column: f.Values[0]})
+		}
+		if f.Values[1] != "" {
+			and = append(and, sq.LtOrEq{f.Column: f.Values[1]})
+		}
+		return and, nil
+	case OpIsNull:
+		if f.Values[0] == true {
+			return sq.Eq{f.Column: nil}, nil
+		}
+		return sq.NotEq{f.Column: nil}, nil
+	default:
+		return sq.Eq{f.Column: f.Values[0]}, nil
+	}
+}
+
+// 请求参数与列的映射
+type FilterField struct {
+	Param  string
+	Column string
+	Op     FilterOp
+}
+
+// 声明式查询规格, 只有声明的参数与排序列会进入 SQL
+type FilterSpec struct {
+	Table   string
+	Columns []string
+	Fields  []FilterField
+	// 排序参数名到列的白名单
+	SortColumns map[string]string
+	DefaultSort string
+	// 大于 0 时启用分页, 读取 Webix 的 start 与 count 参数
+	PageSize uint64
+	// count 参数上限, 为 0 时使用 DefaultMaxPageSize
+	MaxPageSize uint64
+}
+
+// 默认分页行数上限
+const DefaultMaxPageSize = 1000
+
+func NewFilterSpec(table string, columns []string) *FilterSpec {
+	return &FilterSpec{Table: table, Columns: columns, SortColumns: make(map[string]string)}
+}
+
+// 从结构体标签生成查询规格, 标签格式 filter:"column,op[,sort]",
+// 参数名取 form 标签, 其次为 json 标签, 否则为字段名的下划线格式
+func NewFilterSpecFromStruct(table string, columns []string, filter interface{}) (*FilterSpec, error) {
+	spec := NewFilterSpec(table, columns)
+	t := reflect.TypeOf(filter)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("filter %s is not a struct", t)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("filter")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		param := strings.Split(f.Tag.Get("form"), ",")[0]
+		if param == "" {
+			param = strings.Split(f.Tag.Get("json"), ",")[0]
+		}
+		if param == "" || param == "-" {
+			param = common.ToSnakeCase(f.Name)
+		}
+		op := OpEq
+		if len(opts) > 1 && opts[1] != "" {
+			op = FilterOp(opts[1])
+		}
+		spec.Field(param, opts[0], op)
+		if len(opts) > 2 && opts[2] == "sort" {
+			spec.Sortable(param, opts[0])
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// 声明过滤参数
+func (s *FilterSpec) Field(param, column string, op FilterOp) *FilterSpec {
+	s.Fields = append(s.Fields, FilterField{Param: param, Column: column, Op: op})
+	return s
+}
+
+// 声明可排序参数
+func (s *FilterSpec) Sortable(param, column string) *FilterSpec {
+	s.SortColumns[param] = column
+	return s
+}
+
+// 校验声明的列名与操作符
+func (s *FilterSpec) Validate() error {
+	for _, f := range s.Fields {
+		if !IsSafeIdentifier(f.Column) {
+			return fmt.Errorf("invalid filter column %s", f.Column)
+		}
+		switch f.Op {
+		case OpEq, OpIn, OpGte, OpLte, OpLike, OpBetween, OpIsNull:
+		default:
+			return fmt.Errorf("invalid filter op %s", f.Op)
+		}
+	}
+	for _, column := range s.SortColumns {
+		if !IsSafeIdentifier(column) {
+			return fmt.Errorf("invalid sort column %s", column)
+		}
+	}
+	return nil
+}
+
+// 根据请求参数生成查询对象
+func (s *FilterSpec) Build(form *WebForm, resultRef interface{}) (*CrudQuery, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	cq := NewCrudQuery(s.Table, s.Columns, resultRef)
+	for _, f := range s.Fields {
+		val := strings.TrimSpace(form.GetVal(f.Param))
+		if val == "" {
+			continue
+		}
+		filter := CrudFilter{Column: f.Column, Op: f.Op}
+		switch f.Op {
+		case OpIn:
+			for _, v := range strings.Split(val, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					filter.Values = append(filter.Values, v)
+				}
+			}
+			if len(filter.Values) == 0 {
+				continue
+			}
+		case OpBetween:
+			start, end, err := parseBetween(form, f.Param, val)
+			if err != nil {
+				return nil, err
+			}
+			if start == "" && end == "" {
+				continue
+			}
+			filter.Values = []interface{}{start, end}
+		case OpIsNull:
+			switch strings.ToLower(val) {
+			case "1", "true", "yes":
+				filter.Values = []interface{}{true}
+			case "0", "false", "no":
+				filter.Values = []interface{}{false}
+			default:
+				return nil, NewValidationError(f.Param + " 参数无效")
+			}
+		default:
+			filter.Values = []interface{}{val}
+		}
+		cq.Filters = append(cq.Filters, filter)
+	}
+
+	orderBy, err := s.parseSort(form.GetVal2(SortParam, s.DefaultSort))
+	if err != nil {
+		return nil, err
+	}
+	cq.OrderBy = orderBy
+
+	if s.PageSize > 0 {
+		cq.Pager = true
+		if cq.PagePos, err = pageParam(form, "start", 0); err != nil {
+			return nil, err
+		}
+		if cq.PageSize, err = pageParam(form, "count", s.PageSize); err != nil {
+			return nil, err
+		}
+		if cq.PageSize == 0 {
+			cq.PageSize = s.PageSize
+		}
+		max := s.MaxPageSize
+		if max == 0 {
+			max = DefaultMaxPageSize
+		}
+		if cq.PageSize > max {
+			cq.PageSize = max
+		}
+	}
+	return cq, nil
+}
+
+// 读取分页参数, 非数字或负数时返回错误
+func pageParam(form *WebForm, param string, defval uint64) (uint64, error) {
+	val := strings.TrimSpace(form.GetVal(param))
+	if val == "" {
+		return defval, nil
+	}
+	v, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, NewValidationError(param + " 参数无效")
+	}
+	return v, nil
+}
+
+// 区间参数支持 JSON 格式 {"start":"","end":""} 或逗号分隔
+func parseBetween(form *WebForm, param, val string) (string, string, error) {
+	if strings.HasPrefix(val, "{") {
+		dr, err := form.GetDateRange(param)
+		if err != nil {
+			return "", "", NewValidationError(param + " 参数无效")
+		}
+		return dr.Start, dr.End, nil
+	}
+	parts := strings.SplitN(val, ",", 2)
+	if len(parts) != 2 {
+		return "", "", NewValidationError(param + " 参数无效")
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+// 解析排序参数, 如 "name,-created_at", - 表示倒序
+func (s *FilterSpec) parseSort(val string) (string, error) {
+	var orders []string
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		direction := "ASC"
+		if strings.HasPrefix(item, "-") {
+			direction = "DESC"
+			item = item[1:]
+		} else if strings.HasPrefix(item, "+") {
+			item = item[1:]
+		}
+		column, ok := s.SortColumns[item]
+		if !ok {
+			return "", NewValidationError("不支持按 " + item + " 排序")
+		}
+		orders = append(orders, column+" "+direction)
+	}
+	return strings.Join(orders, ", "), nil
+}
+
+// 按查询规格执行列表查询, 启用分页时返回 PageResult, 否则返回结果列表
+func (h *HttpHandler) QueryWithSpec(c echo.Context, spec *FilterSpec, resultRef interface{}) (interface{}, error) {
+	cq, err := spec.Build(NewWebForm(c), resultRef)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.GetAppContext().DBQueryContext(c.Request().Context(), cq); err != nil {
+		return nil, err
+	}
+	if cq.Pager {
+		return cq.ResultPage, nil
+	}
+	return resultRef, nil
+}
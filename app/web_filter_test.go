@@ -0,0 +1,81 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type productFilter struct {
+	Name   string `form:"name" filter:"name,like,sort"`
+	Status string `form:"status" filter:"status,in"`
+	Price  string `filter:"price,between,sort"`
+	Remark string `form:"no_remark" filter:"remark,isnull"`
+}
+
+func TestFilterSpecBuild(t *testing.T) {
+	spec, err := NewFilterSpecFromStruct("product", []string{"*"}, productFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := EmptyWebForm()
+	form.Set("name", "a_b")
+	form.Set("status", "1, 2")
+	form.Set("price", "10,")
+	form.Set("no_remark", "true")
+	form.Set("sort", "-price,name")
+	cq, err := spec.Build(form, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, args := filterSQL(t, cq, "product")
+	expect := "SELECT id FROM product WHERE name LIKE ? AND status IN (?,?) AND (price >= ?) AND remark IS NULL"
+	if sql != expect {
+		t.Fatalf("unexpected sql %s", sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{`%a\_b%`, "1", "2", "10"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+	if cq.OrderBy != "price DESC, name ASC" {
+		t.Fatalf("unexpected order %s", cq.OrderBy)
+	}
+
+	// 分页参数不能为负数, count 不超过 MaxPageSize
+	spec.PageSize = 20
+	spec.MaxPageSize = 50
+	form.Set("sort", "name")
+	form.Set("count", "500")
+	if cq, err = spec.Build(form, nil); err != nil || cq.PageSize != 50 {
+		t.Fatalf("expected count capped, got %+v %v", cq, err)
+	}
+	for _, param := range []string{"start", "count"} {
+		form.Set(param, "-1")
+		if _, err = spec.Build(form, nil); AsAppError(err).Kind != KindValidation {
+			t.Fatalf("expected negative %s validation error, got %v", param, err)
+		}
+		form.Set(param, "0")
+	}
+
+	form.Set("sort", "password")
+	if _, err = spec.Build(form, nil); AsAppError(err).Kind != KindValidation {
+		t.Fatalf("expected sort validation error, got %v", err)
+	}
+	if _, err = NewFilterSpec("product", nil).Field("q", "name; drop", OpEq).Build(form, nil); err == nil {
+		t.Fatal("expected column error")
+	}
+}
+
+// 手工构造的过滤条件需校验列名与参数个数
+func TestCrudFilterInvalid(t *testing.T) {
+	for _, f := range []CrudFilter{
+		{Column: "name", Op: OpEq},
+		{Column: "price", Op: OpBetween, Values: []interface{}{"1"}},
+		{Column: "name) OR (1=1", Op: OpEq, Values: []interface{}{"a"}},
+	} {
+		cq := &CrudQuery{Filters: []CrudFilter{f}}
+		if _, err := cq.filterBuilder(sq.Select("id").From("product")); err == nil {
+			t.Fatalf("expected error for %+v", f)
+		}
+	}
+}
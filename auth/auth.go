@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/ca17/go-common/common"
+)
+
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+
+	DefaultAccessTTL  = 2 * time.Hour
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+
+	// echo.Context 中保存 Claims 的键
+	ContextKey = "auth_claims"
+	// echo JWT 中间件默认保存令牌的键
+	echoUserKey = "user"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = errors.New("token revoked")
+	ErrTokenType    = errors.New("unexpected token type")
+)
+
+// 令牌声明, Subject 为用户 ID
+type Claims struct {
+	Roles  []string `json:"roles,omitempty"`
+	Tenant string   `json:"tenant,omitempty"`
+	Type   string   `json:"typ,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return common.InSlice(role, c.Roles)
+}
+
+// 令牌对
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// 访问令牌有效期, 单位秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// 令牌吊销存储, 按 jti 记录, 过期后可清除.
+// Revoke 需原子地检查并记录, 令牌此前已被吊销时 alreadyRevoked 返回 true
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) (alreadyRevoked bool, err error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// 内存吊销存储, 适用于单实例部署
+type MemoryRevocationStore struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{items: make(map[string]time.Time)}
+}
+
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, exp := range s.items {
+		if exp.Before(now) {
+			delete(s.items, k)
+		}
+	}
+	if _, ok := s.items[jti]; ok {
+		return true, nil
+	}
+	s.items[jti] = expiresAt
+	return false, nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.items[jti]
+	return ok && exp.After(time.Now()), nil
+}
+
+type Config struct {
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// 令牌管理, 负责签发, 校验, 刷新与吊销
+type Manager struct {
+	keys   *KeySet
+	store  RevocationStore
+	config Config
+}
+
+// store 为空时使用内存吊销存储
+func NewManager(keys *KeySet, store RevocationStore, config Config) *Manager {
+	if store == nil {
+		store = NewMemoryRevocationStore()
+	}
+	if config.AccessTTL == 0 {
+		config.AccessTTL = DefaultAccessTTL
+	}
+	if config.RefreshTTL == 0 {
+		config.RefreshTTL = DefaultRefreshTTL
+	}
+	return &Manager{keys: keys, store: store, config: config}
+}
+
+func (m *Manager) Keys() *KeySet {
+	return m.keys
+}
+
+// 签发访问令牌与刷新令牌
+func (m *Manager) Issue(ctx context.Context, claims Claims) (*TokenPair, error) {
+	access, err := m.sign(claims, TokenAccess, m.config.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := m.sign(claims, TokenRefresh, m.config.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.config.AccessTTL / time.Second),
+	}, nil
+}
+
+func (m *Manager) sign(claims Claims, typ string, ttl time.Duration) (string, error) {
+	key := m.keys.Current()
+	if key == nil || key.SignKey == nil {
+		return "", fmt.Errorf("%w: no signing key", ErrUnknownKey)
+	}
+	now := time.Now()
+	claims.Type = typ
+	claims.ID = common.UUID()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	if claims.Issuer == "" {
+		claims.Issuer = m.config.Issuer
+	}
+	token := jwt.NewWithClaims(key.Method, &claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey)
+}
+
+// 校验令牌签名, 有效期与吊销状态
+func (m *Manager) Parse(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := m.keys.Get(kid)
+		if err != nil {
+			return nil, err
+		}
+		// 防止算法替换攻击
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.VerifyKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if m.config.Issuer != "" && claims.Issuer != m.config.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %s", ErrInvalidToken, claims.Issuer)
+	}
+	revoked, err := m.store.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// 校验访问令牌
+func (m *Manager) ParseAccess(ctx context.Context, tokenString string) (*Claims, error) {
+	return m.parseType(ctx, tokenString, TokenAccess)
+}
+
+func (m *Manager) parseType(ctx context.Context, tokenString string, typ string) (*Claims, error) {
+	claims, err := m.Parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != typ {
+		return nil, fmt.Errorf("%w: %s", ErrTokenType, claims.Type)
+	}
+	return claims, nil
+}
+
+// 使用刷新令牌换取新的令牌对, 旧刷新令牌随即吊销.
+// 同一刷新令牌并发刷新时只有一个请求成功, 其余返回 ErrTokenRevoked
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := m.parseType(ctx, refreshToken, TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+	already, err := m.revokeClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if already {
+		return nil, ErrTokenRevoked
+	}
+	return m.Issue(ctx, Claims{
+		Roles:            claims.Roles,
+		Tenant:           claims.Tenant,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: claims.Subject, Audience: claims.Audience},
+	})
+}
+
+// 吊销令牌, 访问令牌与刷新令牌均可
+func (m *Manager) Revoke(ctx context.Context, tokenString string) error {
+	claims, err := m.Parse(ctx, tokenString)
+	if err != nil {
+		return err
+	}
+	_, err = m.revokeClaims(ctx, claims)
+	return err
+}
+
+func (m *Manager) revokeClaims(ctx context.Context, claims *Claims) (bool, error) {
+	var exp time.Time
+	if claims.ExpiresAt != nil {
+		exp = claims.ExpiresAt.Time
+	}
+	return m.store.Revoke(ctx, claims.ID, exp)
+}
+
+// 校验 Authorization: Bearer 访问令牌的中间件, 通过后 Claims 保存在 ContextKey
+func (m *Manager) Middleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper(c) {
+				return next(c)
+			}
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if !strings.HasPrefix(auth, "Bearer ") {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing or malformed token")
+			}
+			claims, err := m.ParseAccess(c.Request().Context(), strings.TrimPrefix(auth, "Bearer "))
+			if err != nil {
+				return &echo.HTTPError{Code: http.StatusUnauthorized, Message: "invalid or expired token", Internal: err}
+			}
+			c.Set(ContextKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// 读取当前请求的 Claims, 兼容 echo JWT 中间件保存的令牌
+func FromContext(c echo.Context) (*Claims, bool) {
+	if claims, ok := c.Get(ContextKey).(*Claims); ok {
+		return claims, true
+	}
+	if mc, ok := echoTokenClaims(c.Get(echoUserKey)); ok {
+		return claimsFromMap(mc), true
+	}
+	return nil, false
+}
+
+// echo 内置 JWT 中间件使用 dgrijalva/jwt-go 的 *Token, 这里通过反射读取其 MapClaims,
+// 避免本包依赖该库
+func echoTokenClaims(token interface{}) (map[string]interface{}, bool) {
+	v := reflect.ValueOf(token)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	f := v.Elem().FieldByName("Claims")
+	if !f.IsValid() || f.IsNil() {
+		return nil, false
+	}
+	cv := f.Elem()
+	mapType := reflect.TypeOf(map[string]interface{}(nil))
+	if !cv.Type().ConvertibleTo(mapType) {
+		return nil, false
+	}
+	return cv.Convert(mapType).Interface().(map[string]interface{}), true
+}
+
+func claimsFromMap(mc map[string]interface{}) *Claims {
+	claims := &Claims{}
+	claims.Subject = mapString(mc, "sub")
+	claims.Tenant = mapString(mc, "tenant")
+	claims.Type = mapString(mc, "typ")
+	claims.ID = mapString(mc, "jti")
+	claims.Issuer = mapString(mc, "iss")
+	if exp, ok := mc["exp"].(float64); ok {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(int64(exp), 0))
+	}
+	switch roles := mc["roles"].(type) {
+	case []interface{}:
+		for _, r := range roles {
+			claims.Roles = append(claims.Roles, fmt.Sprint(r))
+		}
+	case string:
+		claims.Roles = strings.Split(roles, ",")
+	}
+	return claims
+}
+
+func mapString(mc map[string]interface{}, name string) string {
+	switch v := mc[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
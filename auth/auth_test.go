@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func newECKey(t *testing.T, id string) *Key {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewECKey(id, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	keys := NewKeySet(NewHMACKey("k1", []byte("secret")))
+	m := NewManager(keys, nil, Config{Issuer: "test"})
+	claims := Claims{Roles: []string{"admin"}, Tenant: "t1"}
+	claims.Subject = "42"
+	pair, err := m.Issue(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := m.ParseAccess(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access.UserID() != "42" || !access.HasRole("admin") || access.Tenant != "t1" {
+		t.Fatalf("unexpected claims %+v", access)
+	}
+	if _, err = m.ParseAccess(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenType) {
+		t.Fatalf("expected token type error, got %v", err)
+	}
+
+	// 轮换到 ES256 后旧令牌仍可校验
+	keys.Add(newECKey(t, "k2"))
+	if err = keys.SetCurrent("k2"); err != nil {
+		t.Fatal(err)
+	}
+	next, err := m.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected refresh token revoked, got %v", err)
+	}
+	token, _, _ := new(jwt.Parser).ParseUnverified(next.AccessToken, jwt.MapClaims{})
+	if token.Header["kid"] != "k2" || token.Header["alg"] != "ES256" {
+		t.Fatalf("unexpected header %v", token.Header)
+	}
+	if _, err = m.ParseAccess(ctx, pair.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.Revoke(ctx, next.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.ParseAccess(ctx, next.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+
+	// kid 指向 ES256 密钥但使用 HS256 签名
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Type: TokenAccess})
+	forged.Header["kid"] = "k2"
+	s, _ := forged.SignedString([]byte("secret"))
+	if _, err = m.Parse(ctx, s); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected invalid token, got %v", err)
+	}
+}
+
+// 同一刷新令牌并发刷新, 只有一个请求成功
+func TestRefreshConcurrent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	stores := map[string]RevocationStore{
+		"memory": NewMemoryRevocationStore(),
+		"redis":  NewRedisRevocationStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "revoked:"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewManager(NewKeySet(NewHMACKey("k1", []byte("secret"))), store, Config{})
+			claims := Claims{}
+			claims.Subject = "42"
+			pair, err := m.Issue(ctx, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const n = 20
+			var wg sync.WaitGroup
+			var succeeded, revoked int32
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := m.Refresh(ctx, pair.RefreshToken)
+					switch {
+					case err == nil:
+						atomic.AddInt32(&succeeded, 1)
+					case errors.Is(err, ErrTokenRevoked):
+						atomic.AddInt32(&revoked, 1)
+					default:
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			if succeeded != 1 || revoked != n-1 {
+				t.Fatalf("expected one refresh, got %d succeeded %d revoked", succeeded, revoked)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := NewManager(NewKeySet(NewHMACKey("k1", []byte("secret"))), nil, Config{})
+	claims := Claims{}
+	claims.Subject = "7"
+	pair, _ := m.Issue(context.Background(), claims)
+
+	e := echo.New()
+	e.Use(m.Middleware(nil))
+	e.GET("/me", func(c echo.Context) error {
+		claims, _ := FromContext(c)
+		return c.String(http.StatusOK, claims.UserID())
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+pair.AccessToken)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "7" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFromContextLegacyJWT(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "9", "roles": []string{"admin", "ops"}, "tenant": "t2",
+	})
+	signed, _ := token.SignedString([]byte("secret"))
+
+	e := echo.New()
+	e.Use(middleware.JWTWithConfig(middleware.JWTConfig{SigningKey: []byte("secret")}))
+	e.GET("/me", func(c echo.Context) error {
+		claims, ok := FromContext(c)
+		if !ok || !claims.HasRole("ops") || claims.Tenant != "t2" {
+			return c.String(http.StatusInternalServerError, "bad claims")
+		}
+		return c.String(http.StatusOK, claims.UserID())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+signed)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "9" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+}
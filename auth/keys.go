@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// 签名密钥, VerifyKey 用于校验, SignKey 为空时只能用于校验, 如轮换后保留的旧公钥
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// HS256 密钥
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+}
+
+// RS256 密钥, 使用 PEM 格式私钥
+func NewRSAKey(id string, privatePEM []byte) (*Key, error) {
+	priv, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse rsa key %s: %w", id, err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, SignKey: priv, VerifyKey: &priv.PublicKey}, nil
+}
+
+// RS256 校验公钥
+func NewRSAPublicKey(id string, publicPEM []byte) (*Key, error) {
+	pub, err := jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse rsa public key %s: %w", id, err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, VerifyKey: pub}, nil
+}
+
+// ES256 密钥, 使用 PEM 格式私钥
+func NewECKey(id string, privatePEM []byte) (*Key, error) {
+	priv, err := jwt.ParseECPrivateKeyFromPEM(privatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse ec key %s: %w", id, err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodES256, SignKey: priv, VerifyKey: &priv.PublicKey}, nil
+}
+
+// ES256 校验公钥
+func NewECPublicKey(id string, publicPEM []byte) (*Key, error) {
+	pub, err := jwt.ParseECPublicKeyFromPEM(publicPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse ec public key %s: %w", id, err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodES256, VerifyKey: pub}, nil
+}
+
+// 密钥集合, 使用当前密钥签名, 按令牌头部的 kid 选择校验密钥
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	current string
+}
+
+func NewKeySet(current *Key, others ...*Key) *KeySet {
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, k := range others {
+		ks.keys[k.ID] = k
+	}
+	ks.keys[current.ID] = current
+	ks.current = current.ID
+	return ks
+}
+
+// 添加密钥
+func (ks *KeySet) Add(key *Key) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+}
+
+// 切换签名密钥, 旧密钥继续用于校验直到被移除
+func (ks *KeySet) SetCurrent(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, ok := ks.keys[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	if key.SignKey == nil {
+		return fmt.Errorf("key %s has no signing key", id)
+	}
+	ks.current = id
+	return nil
+}
+
+// 移除密钥, 不能移除当前签名密钥
+func (ks *KeySet) Remove(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if id == ks.current {
+		return fmt.Errorf("can not remove current key %s", id)
+	}
+	delete(ks.keys, id)
+	return nil
+}
+
+// 当前签名密钥
+func (ks *KeySet) Current() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.current]
+}
+
+// 按 ID 获取密钥, ID 为空时返回当前密钥
+func (ks *KeySet) Get(id string) (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if id == "" {
+		id = ks.current
+	}
+	key, ok := ks.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
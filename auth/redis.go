@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis 吊销存储, 适用于多实例部署, 使用 SETNX 保证同一 jti 只吊销一次
+type RedisRevocationStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// prefix 为键前缀, 如 "app:revoked:"
+func NewRedisRevocationStore(client redis.Cmdable, prefix string) *RedisRevocationStore {
+	return &RedisRevocationStore{client: client, prefix: prefix}
+}
+
+func (s *RedisRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	// 记录保留到令牌过期, 至少一秒, 避免临近过期时重复吊销
+	ttl := time.Until(expiresAt)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	ok, err := s.client.SetNX(ctx, s.prefix+jti, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.prefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -14,11 +14,48 @@ type WebConfig struct {
 	Secret       string `yaml:"secret"`
 	CertFile     string `yaml:"cert_file"`
 	KeyFile      string `yaml:"key_file"`
-	AuthSkip     string `yaml:"auth_skip"`
+	AuthSkip     string `yaml:"auth_skip"` // 逗号分隔的免认证规则, 如 "POST /login,/static/**"
 	AllowOrigins string `yaml:"allow_origins"`
+	// 指标路径, 如 /metrics, 为空时不启用
+	MetricsPath string `yaml:"metrics_path"`
+	// 指标独立监听端口, 为 0 时与 Web 服务共用端口
+	MetricsPort int `yaml:"metrics_port"`
+	// TLS 证书加载失败时回退为 HTTP, 默认返回错误
+	TLSFallback bool `yaml:"tls_fallback"`
+	// 优雅关闭等待时间, 单位秒, 默认 10
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+	// 限流规则, 按顺序匹配, 每条规则独立计数
+	RateLimits []RateLimitConfig `yaml:"rate_limits"`
+	// 限流计数存储 memory 或 redis, 默认 memory, redis 使用 RedisConfig
+	RateLimitStore string `yaml:"rate_limit_store"`
+	// 访问日志格式 text, json 或 off, 默认 text
+	AccessLog string `yaml:"access_log"`
+	// 逗号分隔的可信代理 IP 或 CIDR, 仅来自这些地址的 X-Forwarded-For 会被采用,
+	// 为空时只使用连接地址
+	TrustedProxies string `yaml:"trusted_proxies"`
+}
+
+// 限流规则
+type RateLimitConfig struct {
+	// 请求方法, 为空匹配全部
+	Method string `yaml:"method"`
+	// 路径规则, 与 AuthSkip 规则格式相同, 如 /sms/**
+	Path string `yaml:"path"`
+	// token_bucket 或 sliding_window, 默认 token_bucket
+	Algorithm string `yaml:"algorithm"`
+	// 窗口内允许的请求数
+	Limit int `yaml:"limit"`
+	// 窗口时长, 单位秒
+	Window int `yaml:"window"`
+	// 令牌桶容量, 默认等于 Limit
+	Burst int `yaml:"burst"`
+	// 限流维度 ip 或 subject, 默认 ip
+	Key string `yaml:"key"`
 }
 
 type DBConfig struct {
+	// 数据库类型 mysql, postgres, sqlite, 默认 mysql
+	Type    string `yaml:"type"`
 	Host    string `yaml:"host"`
 	Port    int    `yaml:"port"`
 	MaxConn int    `yaml:"max_conn"`
@@ -27,6 +64,41 @@ type DBConfig struct {
 	User    string `yaml:"user"`
 	Passwd  string `yaml:"passwd"`
 	Debug  bool `yaml:"debug"`
+	// 只读副本, 未设置的字段继承主库配置
+	Replicas []DBConfig `yaml:"replicas"`
+	// 副本健康检查间隔, 单位秒, 默认 10
+	HealthCheckInterval int `yaml:"health_check_interval"`
+}
+
+// 副本配置, 未设置的字段继承主库配置
+func (c *DBConfig) ReplicaConfig(i int) *DBConfig {
+	r := c.Replicas[i]
+	if r.Type == "" {
+		r.Type = c.Type
+	}
+	if r.Host == "" {
+		r.Host = c.Host
+	}
+	if r.Port == 0 {
+		r.Port = c.Port
+	}
+	if r.MaxConn == 0 {
+		r.MaxConn = c.MaxConn
+	}
+	if r.MaxIdle == 0 {
+		r.MaxIdle = c.MaxIdle
+	}
+	if r.Name == "" {
+		r.Name = c.Name
+	}
+	if r.User == "" {
+		r.User = c.User
+	}
+	if r.Passwd == "" {
+		r.Passwd = c.Passwd
+	}
+	r.Replicas = nil
+	return &r
 }
 
 type GrpcConfig struct {
@@ -8,7 +8,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
+	excelize "github.com/360EntSecGroup-Skylar/excelize/v2"
 
 	"github.com/ca17/go-common/sqltype"
 )
@@ -26,6 +26,7 @@ func WriteToFile(sheet string, records []interface{}, filepath string) error {
 
 }
 
+// Deprecated: 整个文件在内存中生成且临时文件不会被清理, 使用 NewRowWriter 或 app.HttpHandler.ExportQuery 直接写入响应
 func WriteToTmpFile(sheet string, records []interface{}) (string, error) {
 	filename := fmt.Sprintf("%s-%d.xlsx", sheet, time.Now().Unix())
 	tmpdir, _ := ioutil.TempDir("", "excel-export")
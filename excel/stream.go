@@ -0,0 +1,228 @@
+package excel
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	excelize "github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// 导出格式
+const (
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+)
+
+// Excel 打开 UTF-8 CSV 需要 BOM
+const utf8BOM = "\xEF\xBB\xBF"
+
+// 逐行写出的表格, Flush 将已缓冲的行写入底层 Writer, Close 完成写出
+type RowWriter interface {
+	WriteRow(values []interface{}) error
+	Flush() error
+	Close() error
+}
+
+// 按格式创建写出器, 格式为 xlsx, csv 或 tsv
+func NewRowWriter(format string, w io.Writer, sheet string) (RowWriter, error) {
+	switch format {
+	case FormatXLSX:
+		return NewXLSXStreamWriter(w, sheet)
+	case FormatCSV:
+		return NewCSVWriter(w, ',', true)
+	case FormatTSV:
+		return NewCSVWriter(w, '\t', false)
+	}
+	return nil, fmt.Errorf("unsupported export format %s", format)
+}
+
+// 导出文件的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatTSV:
+		return "text/tab-separated-values; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// CSV/TSV 写出器, 默认为以 = + - @ 等开头的文本单元格加 ' 前缀,
+// 防止表格软件将其作为公式执行
+type CSVWriter struct {
+	bw *bufio.Writer
+	cw *csv.Writer
+	// 为 true 时原样写出文本, 不做公式转义
+	RawFormula bool
+}
+
+// CSV/TSV 写出器, 直接写入 w, bom 为 true 时先写入 UTF-8 BOM
+func NewCSVWriter(w io.Writer, comma rune, bom bool) (*CSVWriter, error) {
+	bw := bufio.NewWriter(w)
+	if bom {
+		if _, err := bw.WriteString(utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+	cw := csv.NewWriter(bw)
+	cw.Comma = comma
+	return &CSVWriter{bw: bw, cw: cw}, nil
+}
+
+func (w *CSVWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = cellString(v)
+		if !w.RawFormula && isText(v) {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	return w.cw.Write(record)
+}
+
+func (w *CSVWriter) Flush() error {
+	w.cw.Flush()
+	if err := w.cw.Error(); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+func (w *CSVWriter) Close() error {
+	return w.Flush()
+}
+
+// 数值等非文本类型不会被识别为公式, 负数无需转义
+func isText(v interface{}) bool {
+	switch v.(type) {
+	case string, []byte, fmt.Stringer:
+		return true
+	}
+	return false
+}
+
+// 公式起始字符前加 '
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func cellString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	case fmt.Stringer:
+		return val.String()
+	}
+	return fmt.Sprint(v)
+}
+
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+	closed bool
+}
+
+// xlsx 写出器, 使用 excelize 的 StreamWriter 降低内存占用, 行数据超过 16MB 后缓存到临时文件.
+// 在 Close 之前不会向 w 写入任何内容, Close 时打包写入 w 并删除临时文件
+func NewXLSXStreamWriter(w io.Writer, sheet string) (RowWriter, error) {
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	f := excelize.NewFile()
+	if sheet != "Sheet1" {
+		f.SetSheetName("Sheet1", sheet)
+	}
+	stream, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{w: w, file: f, stream: stream}, nil
+}
+
+func (w *xlsxWriter) WriteRow(values []interface{}) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		row[i] = v
+	}
+	return w.stream.SetRow(cell, row)
+}
+
+// xlsx 需在 Close 时整体打包, 无法提前写出
+func (w *xlsxWriter) Flush() error {
+	return nil
+}
+
+func (w *xlsxWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.w)
+}
+
+// 放弃写出, 仅释放临时文件. 当前版本的 excelize 只在打包时删除临时文件
+func Discard(rw RowWriter) {
+	if w, ok := rw.(*xlsxWriter); ok && !w.closed {
+		w.w = ioutil.Discard
+		w.Close()
+	}
+}
+
+// 生成 Content-Disposition, filename* 使用 UTF-8 编码, filename 为 ASCII 兼容名称
+func ContentDisposition(filename string) string {
+	var ascii strings.Builder
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r > 0x7e:
+			ascii.WriteByte('_')
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii.String(), encodeRFC5987(filename))
+}
+
+// RFC 5987 编码, 仅保留 attr-char
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[c>>4])
+		sb.WriteByte(hex[c&15])
+	}
+	return sb.String()
+}
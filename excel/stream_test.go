@@ -0,0 +1,28 @@
+package excel
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVWriterEscapeFormula(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf, ',', false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteRow([]interface{}{"=1+2", "+cmd", "-x", "@SUM(A1)", "ok", -5, []byte("=A1")})
+	w.Close()
+	if got := buf.String(); got != "'=1+2,'+cmd,'-x,'@SUM(A1),ok,-5,'=A1\n" {
+		t.Fatalf("unexpected csv %q", got)
+	}
+
+	buf.Reset()
+	w, _ = NewCSVWriter(&buf, ',', false)
+	w.RawFormula = true
+	w.WriteRow([]interface{}{"=1+2", "-x"})
+	w.Close()
+	if got := buf.String(); got != "=1+2,-x\n" {
+		t.Fatalf("unexpected raw csv %q", got)
+	}
+}
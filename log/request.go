@@ -0,0 +1,78 @@
+package log
+
+import (
+	"context"
+	"strings"
+
+	"github.com/op/go-logging"
+)
+
+type requestIDContextKey struct{}
+
+// 保存请求 ID 到 context
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// 读取 context 中的请求 ID
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// *Ctx 函数使用的 logger, 经包装函数调用, 需要多跳过一层调用栈
+var ctxLog = func() *logging.Logger {
+	l := logging.MustGetLogger(ModuleSystem)
+	l.ExtraCalldepth = 1
+	return l
+}()
+
+// 带请求 ID 的日志, ctx 中存在请求 ID 时输出 [请求 ID] 前缀
+func ErrorCtx(ctx context.Context, args ...interface{}) {
+	ctxLog.Error(withRequestID(ctx, args)...)
+}
+
+func ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	ctxLog.Errorf(withRequestIDf(ctx, format), args...)
+}
+
+func InfoCtx(ctx context.Context, args ...interface{}) {
+	ctxLog.Info(withRequestID(ctx, args)...)
+}
+
+func InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	ctxLog.Infof(withRequestIDf(ctx, format), args...)
+}
+
+func WarningCtx(ctx context.Context, args ...interface{}) {
+	ctxLog.Warning(withRequestID(ctx, args)...)
+}
+
+func WarningfCtx(ctx context.Context, format string, args ...interface{}) {
+	ctxLog.Warningf(withRequestIDf(ctx, format), args...)
+}
+
+func DebugCtx(ctx context.Context, args ...interface{}) {
+	ctxLog.Debug(withRequestID(ctx, args)...)
+}
+
+func DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	ctxLog.Debugf(withRequestIDf(ctx, format), args...)
+}
+
+func withRequestID(ctx context.Context, args []interface{}) []interface{} {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return append([]interface{}{"[" + id + "]"}, args...)
+	}
+	return args
+}
+
+func withRequestIDf(ctx context.Context, format string) string {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return "[" + strings.ReplaceAll(id, "%", "%%") + "] " + format
+	}
+	return format
+}
@@ -0,0 +1,23 @@
+package log
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := context.Background()
+	if got := withRequestIDf(ctx, "a %s"); got != "a %s" {
+		t.Fatalf("no id format %q", got)
+	}
+	ctx = ContextWithRequestID(ctx, "r1")
+	if id := RequestIDFromContext(ctx); id != "r1" {
+		t.Fatalf("context id %q", id)
+	}
+	if got := withRequestIDf(ctx, "a %s"); got != "[r1] a %s" {
+		t.Fatalf("format %q", got)
+	}
+	if got := withRequestID(ctx, []interface{}{"a"}); len(got) != 2 || got[0] != "[r1]" {
+		t.Fatalf("args %v", got)
+	}
+}
@@ -0,0 +1,457 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ca17/go-common/log"
+)
+
+const (
+	DefaultTable    = "schema_migrations"
+	DefaultLockName = "schema_migrations_lock"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrNoDownMigration  = errors.New("migration has no down script")
+	ErrLockTimeout      = errors.New("acquire migration lock timeout")
+)
+
+// 迁移文件命名格式 {version}_{name}.up.sql, {version}_{name}.down.sql
+var filenameRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// 脚本中单独一行为该注释时不拆分语句, 整个脚本作为一条语句执行,
+// 适用于 mysql 的存储过程与触发器等包含分号的语句
+const NoSplitDirective = "-- migrate:nosplit"
+
+// 单个版本的迁移脚本
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// 迁移状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// 已执行的脚本与当前文件内容不一致
+	Modified bool
+	// 已执行但找不到对应文件
+	Missing bool
+}
+
+type appliedRecord struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// 数据库迁移工具
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []*Migration
+	// 版本记录表
+	Table string
+	// 多实例并发执行时的锁名称
+	LockName    string
+	LockTimeout time.Duration
+}
+
+// 从 fs.FS 的 dir 目录读取迁移脚本, 支持 embed.FS
+func New(db *sqlx.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := filenameRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := versions[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			versions[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s, %s", version, mg.Name, match[2])
+		}
+		if match[3] == "up" {
+			mg.Up = string(content)
+		} else {
+			mg.Down = string(content)
+		}
+	}
+
+	m := &Migrator{db: db, Table: DefaultTable, LockName: DefaultLockName, LockTimeout: time.Minute}
+	for _, mg := range versions {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mg.Version, mg.Name)
+		}
+		sum := sha256.Sum256([]byte(mg.Up))
+		mg.Checksum = hex.EncodeToString(sum[:])
+		m.migrations = append(m.migrations, mg)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return m, nil
+}
+
+// 从本地目录读取迁移脚本
+func NewFromDir(db *sqlx.DB, dir string) (*Migrator, error) {
+	return New(db, os.DirFS(dir), ".")
+}
+
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// 执行全部未执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, -1)
+}
+
+// 回滚最近的 steps 个迁移
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err = m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if err = m.runDown(ctx, conn, mg); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// 迁移到指定版本, 高于 version 的已执行迁移将回滚, version 小于 0 表示最新版本
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err = m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && version >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; ok && mg.Version > version {
+				if err = m.runDown(ctx, conn, mg); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mg := range m.migrations {
+			if version >= 0 && mg.Version > version {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err = m.runUp(ctx, conn, mg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 校验已执行迁移的脚本未被修改
+func (m *Migrator) verify(applied map[int64]appliedRecord) error {
+	for _, mg := range m.migrations {
+		if rec, ok := applied[mg.Version]; ok && rec.Checksum != mg.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+		}
+	}
+	return nil
+}
+
+// 查询迁移状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			st := Status{Version: mg.Version, Name: mg.Name}
+			if rec, ok := applied[mg.Version]; ok {
+				st.Applied = true
+				st.AppliedAt = rec.AppliedAt
+				st.Modified = rec.Checksum != mg.Checksum
+				delete(applied, mg.Version)
+			}
+			result = append(result, st)
+		}
+		for _, rec := range applied {
+			result = append(result, Status{Version: rec.Version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Missing: true})
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Version < result[j].Version
+		})
+		return nil
+	})
+	return result, err
+}
+
+func (m *Migrator) runUp(ctx context.Context, conn *sql.Conn, mg *Migration) error {
+	log.Infof("migrate up %d_%s", mg.Version, mg.Name)
+	return m.inTx(ctx, conn, mg.Up, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, m.db.Rebind(fmt.Sprintf(
+			"INSERT INTO %s (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)", m.Table)),
+			mg.Version, mg.Name, mg.Checksum, time.Now())
+		return err
+	})
+}
+
+func (m *Migrator) runDown(ctx context.Context, conn *sql.Conn, mg *Migration) error {
+	if mg.Down == "" {
+		return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, mg.Version, mg.Name)
+	}
+	log.Infof("migrate down %d_%s", mg.Version, mg.Name)
+	return m.inTx(ctx, conn, mg.Down, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, m.db.Rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", m.Table)), mg.Version)
+		return err
+	})
+}
+
+// 在事务中执行脚本与版本记录. 注意 mysql 的 DDL 语句会隐式提交
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitScript(script) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			_ = tx.Rollback()
+			log.Error(err)
+			return err
+		}
+	}
+	if err = record(tx); err != nil {
+		_ = tx.Rollback()
+		log.Error(err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// 读取已执行的迁移
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedRecord, error) {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`, m.Table))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", m.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int64]appliedRecord)
+	for rows.Next() {
+		var rec appliedRecord
+		if err = rows.Scan(&rec.Version, &rec.Name, &rec.Checksum, &rec.AppliedAt); err != nil {
+			return nil, err
+		}
+		result[rec.Version] = rec
+	}
+	return result, rows.Err()
+}
+
+// 使用独立连接执行, 锁与迁移在同一连接上完成
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+// 获取数据库锁, 防止多实例同时迁移. sqlite 为单写入, 不需要加锁
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	return m.withConn(ctx, func(conn *sql.Conn) error {
+		switch m.db.DriverName() {
+		case "mysql":
+			var locked sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.LockName, int(m.LockTimeout.Seconds())).Scan(&locked)
+			if err != nil {
+				return err
+			}
+			if locked.Int64 != 1 {
+				return ErrLockTimeout
+			}
+			defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.LockName)
+		case "postgres", "pgx":
+			h := fnv.New64a()
+			h.Write([]byte(m.LockName))
+			key := int64(h.Sum64())
+			lctx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+			defer cancel()
+			if _, err := conn.ExecContext(lctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+				if lctx.Err() != nil {
+					return ErrLockTimeout
+				}
+				return err
+			}
+			defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		}
+		return fn(conn)
+	})
+}
+
+// 包含 NoSplitDirective 时不拆分
+func splitScript(script string) []string {
+	for _, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == NoSplitDirective {
+			return []string{script}
+		}
+	}
+	return SplitStatements(script)
+}
+
+// postgres 的 $$ 或 $tag$ 引用开始标记, 不是时返回空
+func dollarTag(runes []rune, i int) string {
+	if i > 0 && isIdentRune(runes[i-1]) {
+		return ""
+	}
+	for j := i + 1; j < len(runes); j++ {
+		c := runes[j]
+		if c == '$' {
+			return string(runes[i : j+1])
+		}
+		if !isIdentRune(c) || j == i+1 && c >= '0' && c <= '9' {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c > 0x7f
+}
+
+// 按分号拆分 SQL 脚本, 忽略引号, 注释与 postgres $$ 引用中的分号.
+// 不支持 mysql 的 # 注释与 DELIMITER, 此类脚本使用 NoSplitDirective
+func SplitStatements(script string) []string {
+	var stmts []string
+	var buf strings.Builder
+	var quote rune
+	lineComment, blockComment := false, false
+	runes := []rune(script)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+				buf.WriteRune(c)
+			}
+			continue
+		case blockComment:
+			if c == '*' && next == '/' {
+				blockComment = false
+				i++
+			}
+			continue
+		case quote != 0:
+			buf.WriteRune(c)
+			if c == '\\' && quote != '`' && next != 0 {
+				buf.WriteRune(next)
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '-' && next == '-':
+			lineComment = true
+			continue
+		case c == '/' && next == '*':
+			blockComment = true
+			i++
+			continue
+		case c == '$':
+			if tag := dollarTag(runes, i); tag != "" {
+				// 原样保留到结束标记
+				rest := string(runes[i+len([]rune(tag)):])
+				body := rest
+				if end := strings.Index(rest, tag); end >= 0 {
+					body = rest[:end+len(tag)]
+				}
+				buf.WriteString(tag + body)
+				i += len([]rune(tag+body)) - 1
+				continue
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';':
+			flush()
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	flush()
+	return stmts
+}
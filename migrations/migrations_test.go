@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMigrate(t *testing.T) {
+	db := sqlx.MustOpen("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"sql/1_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT); -- user table\nINSERT INTO user (name) VALUES ('a;b');")},
+		"sql/1_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+		"sql/2_add_role.up.sql":      {Data: []byte("CREATE TABLE role (id INTEGER PRIMARY KEY);")},
+		"sql/2_add_role.down.sql":    {Data: []byte("DROP TABLE role;")},
+	}
+	m, err := New(db, fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err = db.Get(&name, "SELECT name FROM user"); err != nil || name != "a;b" {
+		t.Fatalf("unexpected %s %v", name, err)
+	}
+
+	if err = m.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil || len(status) != 2 || !status[0].Applied || status[1].Applied {
+		t.Fatalf("unexpected status %+v %v", status, err)
+	}
+
+	if err = m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["sql/1_create_user.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY);")}
+	m, _ = New(db, fsys, "sql")
+	if err = m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if err = m.Down(ctx, 2); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch on down, got %v", err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+	NEW.name := 'a;b'; RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE FUNCTION g() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;
+SELECT $1, a$b FROM t; /* c; */ SELECT 2;`
+	stmts := SplitStatements(script)
+	if len(stmts) != 4 {
+		t.Fatalf("unexpected statements %q", stmts)
+	}
+	if !strings.HasSuffix(stmts[0], "$$ LANGUAGE plpgsql") || stmts[1] != "CREATE FUNCTION g() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql" {
+		t.Fatalf("unexpected dollar quoted statements %q", stmts[:2])
+	}
+	if stmts[2] != "SELECT $1, a$b FROM t" || stmts[3] != "SELECT 2" {
+		t.Fatalf("unexpected statements %q", stmts[2:])
+	}
+
+	nosplit := "-- migrate:nosplit\nCREATE TRIGGER t AFTER INSERT ON a FOR EACH ROW BEGIN UPDATE b SET n = n + 1; END;"
+	if stmts = splitScript(nosplit); len(stmts) != 1 || stmts[0] != nosplit {
+		t.Fatalf("unexpected nosplit statements %q", stmts)
+	}
+}
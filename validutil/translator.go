@@ -0,0 +1,138 @@
+package validutil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	zh_translations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// 内置语言
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+)
+
+// 自定义标签的英文信息
+var enMessages = map[string]string{
+	"cnmobile": "{0} must be a valid mobile number",
+	"cnphone":  "{0} must be a valid phone number",
+	"cnip":     "{0} must be a valid IP address",
+	"cnurl":    "{0} must be a valid URL",
+}
+
+// 多语言校验器, 同一个 Validate 为每种语言注册翻译
+type Translators struct {
+	Validate *validator.Validate
+	uni      *ut.UniversalTranslator
+}
+
+// 创建支持 zh 与 en 的校验器, fallback 为未匹配到语言时使用的语言
+func NewTranslators(fallback string) (*Translators, error) {
+	zhLocale, enLocale := zh.New(), en.New()
+	fb := zhLocale
+	if fallback == LocaleEn {
+		fb = enLocale
+	}
+	t := &Translators{Validate: validator.New(), uni: ut.New(fb, zhLocale, enLocale)}
+	t.Validate.RegisterTagNameFunc(fieldName)
+	if err := RegisterValidations(t.Validate); err != nil {
+		return nil, err
+	}
+
+	zhTrans, _ := t.uni.GetTranslator(LocaleZh)
+	if err := zh_translations.RegisterDefaultTranslations(t.Validate, zhTrans); err != nil {
+		return nil, err
+	}
+	if err := registerTranslations(t.Validate, zhTrans, zhMessages); err != nil {
+		return nil, err
+	}
+	enTrans, _ := t.uni.GetTranslator(LocaleEn)
+	if err := en_translations.RegisterDefaultTranslations(t.Validate, enTrans); err != nil {
+		return nil, err
+	}
+	if err := registerTranslations(t.Validate, enTrans, enMessages); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 按优先顺序查找翻译器, 如 Translator("en-US", "zh"), 未找到时返回默认语言
+func (t *Translators) Translator(locales ...string) ut.Translator {
+	var candidates []string
+	for _, l := range locales {
+		l = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(l), "-", "_"))
+		if l == "" {
+			continue
+		}
+		candidates = append(candidates, l)
+		if i := strings.IndexByte(l, '_'); i > 0 {
+			candidates = append(candidates, l[:i])
+		}
+	}
+	trans, _ := t.uni.FindTranslator(candidates...)
+	return trans
+}
+
+// 覆盖指定语言下某个标签的信息, {0} 为字段名, {1} 为标签参数.
+// 需在初始化阶段调用, 不能与校验并发执行
+func (t *Translators) SetMessage(locale string, tag string, msg string) error {
+	trans, found := t.uni.GetTranslator(locale)
+	if !found {
+		return fmt.Errorf("unsupported locale %s", locale)
+	}
+	return registerTranslations(t.Validate, trans, map[string]string{tag: msg})
+}
+
+// 校验结构体, 失败时返回以字段名为键, 按 locales 翻译的信息
+func (t *Translators) Struct(s interface{}, locales ...string) (map[string]string, error) {
+	err := t.Validate.Struct(s)
+	if err == nil {
+		return nil, nil
+	}
+	if msgs := TranslateErrors(err, t.Translator(locales...)); msgs != nil {
+		return msgs, nil
+	}
+	return nil, err
+}
+
+// 解析 Accept-Language, 按权重从高到低返回语言, 如 "en-US,en;q=0.9,zh;q=0.8"
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	result := make([]string, len(langs))
+	for i, l := range langs {
+		result[i] = l.tag
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package validutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("zh;q=0.5, en-US, fr;q=0, *;q=0.1")
+	if !reflect.DeepEqual(got, []string{"en-US", "zh"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestTranslators(t *testing.T) {
+	tr, err := NewTranslators(LocaleZh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type form struct {
+		Name   string `json:"name" validate:"required"`
+		Mobile string `json:"mobile" validate:"cnmobile"`
+	}
+	msgs, err := tr.Struct(&form{Mobile: "1"}, "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs["name"] != "name is a required field" || msgs["mobile"] != "mobile must be a valid mobile number" {
+		t.Fatalf("en messages %v", msgs)
+	}
+	msgs, _ = tr.Struct(&form{Mobile: "1"}, "fr")
+	if msgs["name"] != "name为必填字段" {
+		t.Fatalf("fallback messages %v", msgs)
+	}
+
+	if err = tr.SetMessage(LocaleEn, "required", "{0} cannot be empty"); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ = tr.Struct(&form{Mobile: "13800138000"}, "en")
+	if msgs["name"] != "name cannot be empty" {
+		t.Fatalf("override messages %v", msgs)
+	}
+	if err = tr.SetMessage("de", "required", "x"); err == nil {
+		t.Fatal("expected unsupported locale error")
+	}
+}
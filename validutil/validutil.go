@@ -1,7 +1,10 @@
 package validutil
 
 import (
+	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/go-playground/locales/zh"
@@ -60,6 +63,9 @@ const (
 )
 
 var (
+	// 多语言校验器, 默认语言为 zh
+	Translations *Translators
+	// Translations 的校验器与中文翻译器
 	Validtool  *validator.Validate
 	ValidTrans *ut.Translator
 )
@@ -71,21 +77,132 @@ func NewValidatorTrans() (*validator.Validate, *ut.Translator, error) {
 	uni = ut.New(zh, zh)
 	trans, _ := uni.GetTranslator("zh")
 	validate = validator.New()
+	validate.RegisterTagNameFunc(fieldName)
 	err := zh_translations.RegisterDefaultTranslations(validate, trans)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err = RegisterValidations(validate); err != nil {
+		return nil, nil, err
+	}
+	if err = registerTranslations(validate, trans, zhMessages); err != nil {
+		return nil, nil, err
+	}
 	return validate, &trans, nil
 }
 
+// 错误信息中的字段名, 依次取 json, form, query, param 标签, 与请求参数名一致.
+// 跳过值为 - 的标签, 全部为 - 时返回空
+func fieldName(f reflect.StructField) string {
+	ignored := false
+	for _, tag := range []string{"json", "form", "query", "param"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			ignored = true
+			continue
+		}
+		if name != "" {
+			return name
+		}
+	}
+	if ignored {
+		return ""
+	}
+	return f.Name
+}
+
+// 注册 cnmobile, cnphone, cnip, cnurl 校验标签, cnip 与 cnurl 使用本包的规则, cnurl 可省略协议.
+// 内置的 ip 与 url 标签保持不变
+func RegisterValidations(validate *validator.Validate) error {
+	checks := map[string]func(val interface{}) bool{
+		"cnmobile": IsCnMobile,
+		"cnphone":  IsCnPhone,
+		"cnip":     IsIP,
+		"cnurl":    IsURL,
+	}
+	for tag, check := range checks {
+		check := check
+		err := validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			val, ok := fieldString(fl.Field())
+			return ok && check(val)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 字符串与整数字段转换为字符串校验, 如整数类型的手机号码, 其他类型校验失败
+func fieldString(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	}
+	return "", false
+}
+
+// 自定义标签的中文信息
+var zhMessages = map[string]string{
+	"cnmobile": "{0}必须是有效的手机号码",
+	"cnphone":  "{0}必须是有效的电话号码",
+	"cnip":     "{0}必须是有效的IP地址",
+	"cnurl":    "{0}必须是有效的URL",
+}
+
+func registerTranslations(validate *validator.Validate, trans ut.Translator, messages map[string]string) error {
+	for tag, msg := range messages {
+		msg := msg
+		err := validate.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+			return ut.Add(tag, msg, true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, err := ut.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.(error).Error()
+			}
+			return t
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 将校验错误翻译为以字段名为键的信息, 嵌套字段的键形如 address.city.
+// 非校验错误返回 nil
+func TranslateErrors(err error, trans ut.Translator) map[string]string {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		key := fe.Namespace()
+		if i := strings.IndexByte(key, '.'); i >= 0 {
+			key = key[i+1:]
+		}
+		result[key] = fe.Translate(trans)
+	}
+	return result
+}
+
 func init() {
 	var err error
-	Validtool, ValidTrans, err = NewValidatorTrans()
+	Translations, err = NewTranslators(LocaleZh)
 	common.Must(err)
+	Validtool = Translations.Validate
+	zhTrans := Translations.Translator(LocaleZh)
+	ValidTrans = &zhTrans
 }
 
+// 整体匹配, 包含 | 的表达式整体加 ^(?:...)$ 锚定, 而不是只锚定首尾分支
 func regexpCompile(str string) *regexp.Regexp {
-	return regexp.MustCompile("^" + str + "$")
+	return regexp.MustCompile("^(?:" + str + ")$")
 }
 
 var (
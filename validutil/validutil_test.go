@@ -15,3 +15,55 @@ func TestTransZh(t *testing.T) {
 	}
 
 }
+
+func TestRegisterValidations(t *testing.T) {
+	type form struct {
+		Mobile string `json:"mobile" validate:"cnmobile"`
+		Phone  string `form:"phone" validate:"cnphone"`
+		Addr   string `json:"addr" validate:"cnip"`
+		Site   string `json:"site" validate:"cnurl"`
+		Link   string `json:"link" validate:"url"`
+	}
+	err := Validtool.Struct(&form{Mobile: "13800138000", Phone: "0578-12345678", Addr: "10.0.0.1", Site: "example.com/a", Link: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内置 url 标签不受影响, 仍要求协议
+	err = Validtool.Struct(&form{Mobile: "1380013800", Phone: "x", Addr: "10.0.0.256", Site: "a b", Link: "example.com"})
+	msgs := TranslateErrors(err, *ValidTrans)
+	if len(msgs) != 5 || msgs["mobile"] != "mobile必须是有效的手机号码" || msgs["addr"] != "addr必须是有效的IP地址" || msgs["phone"] == "" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+}
+
+// 包含 | 的表达式需整体匹配
+func TestRegexpCompileAlternation(t *testing.T) {
+	re := regexpCompile(`a|b`)
+	for val, want := range map[string]bool{"a": true, "b": true, "ab": false, "xb": false, "ax": false} {
+		if re.MatchString(val) != want {
+			t.Fatalf("%q: expected %v", val, want)
+		}
+	}
+	if IsIP("10.0.0.256") || IsIP("x::1") || !IsIP("::1") || !IsIP("10.0.0.1") {
+		t.Fatal("unexpected IsIP result")
+	}
+}
+
+func TestFieldNameAndIntValues(t *testing.T) {
+	type form struct {
+		ID     int64  `param:"id" json:"-" validate:"gt=0"`
+		Skip   string `json:"-" validate:"required"`
+		Mobile int64  `json:"mobile" validate:"cnmobile"`
+		Addr   []byte `json:"addr" validate:"cnip"`
+	}
+	msgs, err := Translations.Struct(&form{Mobile: 13800138000, Addr: []byte("10.0.0.1")}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 || msgs["id"] != "id must be greater than 0" || msgs["addr"] == "" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+	if msgs, _ = Translations.Struct(&form{ID: 1, Skip: "x", Mobile: 1380013800}, "en"); msgs["mobile"] == "" {
+		t.Fatalf("expected invalid int mobile, got %v", msgs)
+	}
+}